@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/fernandofreamunde/ika/internal/config"
+	"github.com/fernandofreamunde/ika/internal/database"
 	"github.com/fernandofreamunde/ika/internal/server"
 )
 
@@ -36,9 +40,55 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	done <- true
 }
 
+// migrate runs the migrate subcommand: migrate up|down|status|redo.
+func migrate(cfg config.Config, args []string) {
+	if len(args) != 1 {
+		log.Fatalf("Usage: %s [-config file] migrate up|down|status|redo", os.Args[0])
+	}
+
+	db := database.New(cfg.Database)
+	defer db.Close()
+
+	if err := db.Migrate(context.Background(), args[0]); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func main() {
 
-	server := server.NewServer()
+	configPath := flag.String("config", "", "YAML config file, defaults to $CONFIG_FILE")
+	autoMigrate := flag.Bool("auto-migrate", false, "apply pending migrations before serving")
+	flag.Parse()
+
+	cfg, err := config.Read(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Migrating only needs the database, so it runs where the rest of the
+	// settings, like APP_SECRET, are not set.
+	if flag.Arg(0) == "migrate" {
+		if err := cfg.Database.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		migrate(cfg, flag.Args()[1:])
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.New(cfg.Database)
+	defer db.Close()
+
+	if *autoMigrate || cfg.AutoMigrate {
+		if err := db.Migrate(context.Background(), database.MigrateUp); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := server.NewServer(cfg, db)
 
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
@@ -46,7 +96,7 @@ func main() {
 	// Run graceful shutdown in a separate goroutine
 	go gracefulShutdown(server, done)
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -17,11 +16,11 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID
-	Email     string
-	Nickname  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Nickname  string    `json:"nickname"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type LoginResponse struct {
@@ -30,7 +29,19 @@ type LoginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-var appSecret = os.Getenv("APP_SECRET")
+// TokenIssuer signs and validates the JWTs handed out to users.
+type TokenIssuer struct {
+	secret string
+}
+
+func NewTokenIssuer(secret string) *TokenIssuer {
+	return &TokenIssuer{secret: secret}
+}
+
+// ValidateJWT returns the user the token was issued to.
+func (ti *TokenIssuer) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	return ValidateJWT(tokenString, ti.secret)
+}
 
 func HashPassword(pw string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
@@ -42,10 +53,10 @@ func CheckPasswordHash(hash, pw string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
 }
 
-func AuthenticateUser(u db.User, ctx context.Context, dbq func() *db.Queries) (LoginResponse, error) {
+func (ti *TokenIssuer) AuthenticateUser(u db.User, ctx context.Context, dbq func() *db.Queries) (LoginResponse, error) {
 
 	expiresIn := 60 * 60
-	jwt, _ := MakeJWT(u.ID, appSecret, time.Duration(expiresIn)*time.Second)
+	jwt, _ := MakeJWT(u.ID, ti.secret, time.Duration(expiresIn)*time.Second)
 	refreshToken, _ := MakeRefreshToken()
 
 	_, err := dbq().CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
@@ -73,7 +84,7 @@ func AuthenticateUser(u db.User, ctx context.Context, dbq func() *db.Queries) (L
 	}, nil
 }
 
-func RefreshJWT(h http.Header, ctx context.Context, q func() *db.Queries) (string, error) {
+func (ti *TokenIssuer) RefreshJWT(h http.Header, ctx context.Context, q func() *db.Queries) (string, error) {
 
 	tokenString, _ := GetApiKey(h)
 	token, err := q().GetRefreshToken(ctx, tokenString)
@@ -87,7 +98,7 @@ func RefreshJWT(h http.Header, ctx context.Context, q func() *db.Queries) (strin
 	}
 
 	expiresIn := 60 * 60
-	jwt, err := MakeJWT(token.UserID.UUID, appSecret, time.Duration(expiresIn)*time.Second)
+	jwt, err := MakeJWT(token.UserID.UUID, ti.secret, time.Duration(expiresIn)*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("Could not create JWT.")
 	}
@@ -114,13 +125,13 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return t.SignedString([]byte(tokenSecret))
 }
 
-func ValidateJWT(tokenString string) (uuid.UUID, error) {
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 
 	t, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpcted signing method: %v", token.Header["alg"])
 		}
-		return []byte(appSecret), nil
+		return []byte(tokenSecret), nil
 	})
 
 	if err != nil {
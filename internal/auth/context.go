@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// WithUserID returns a copy of the context carrying the authenticated user.
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// UserID returns the authenticated user of the request the context belongs to.
+func UserID(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(contextKey{}).(uuid.UUID)
+	return userID, ok
+}
+
+// MustUserID is UserID for handlers behind the auth middleware, where a
+// missing user is a wiring mistake.
+func MustUserID(ctx context.Context) uuid.UUID {
+	userID, ok := UserID(ctx)
+	if !ok {
+		panic("auth: no authenticated user in context")
+	}
+	return userID
+}
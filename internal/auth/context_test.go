@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUserIDFromContext(t *testing.T) {
+	userId := uuid.New()
+
+	got, ok := UserID(WithUserID(context.Background(), userId))
+	if !ok || got != userId {
+		t.Fatalf("UserID = %v, %v, want %v, true", got, ok, userId)
+	}
+
+	if _, ok := UserID(context.Background()); ok {
+		t.Fatalf("Expected no user in an empty context!")
+	}
+}
@@ -0,0 +1,418 @@
+package chatroom
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/storage"
+	"github.com/fernandofreamunde/ika/internal/thumbnail"
+	"github.com/google/uuid"
+)
+
+const MaxAttachmentSize = 25 << 20
+
+// Image types clients can safely display inline. Anything else, SVG
+// included, is served as a download.
+var imageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	ErrAttachmentNotFound = errors.New("Attachment not found.")
+	ErrAttachmentTooLarge = errors.New("Attachments can not be larger than 25 MiB.")
+)
+
+// Attachment is a file attached to a message as returned by the API.
+type Attachment struct {
+	ID        uuid.UUID `json:"id"`
+	MessageID uuid.UUID `json:"message_id"`
+	URL       string    `json:"url"`
+	Name      string    `json:"name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	// Checksum is the hex encoded SHA-256 of the content.
+	Checksum string `json:"checksum"`
+	// Width and Height are only known for images.
+	Width     int32      `json:"width,omitempty"`
+	Height    int32      `json:"height,omitempty"`
+	Thumbnail *Thumbnail `json:"thumbnail,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Thumbnail is set once the background worker has generated it.
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+func NewAttachment(a db.Attachment, chatroomId uuid.UUID) *Attachment {
+	url := fmt.Sprintf("/api/chatrooms/%s/attachments/%s", chatroomId, a.ID)
+
+	attachment := &Attachment{
+		ID:        a.ID,
+		MessageID: a.MessageID,
+		URL:       url,
+		Name:      a.Name,
+		MimeType:  a.MimeType,
+		Size:      a.Size,
+		Checksum:  a.Checksum,
+		Width:     a.Width.Int32,
+		Height:    a.Height.Int32,
+		CreatedAt: a.CreatedAt,
+	}
+
+	if a.ThumbnailStatus == thumbnail.StatusReady {
+		attachment.Thumbnail = &Thumbnail{
+			URL:    url + "/thumbnail",
+			Width:  a.ThumbnailWidth.Int32,
+			Height: a.ThumbnailHeight.Int32,
+		}
+	}
+
+	return attachment
+}
+
+type SendAttachmentParams struct {
+	AuthorID   uuid.UUID
+	ChatroomID uuid.UUID
+	Caption    string
+	ReplyToID  uuid.NullUUID
+	Name       string
+	Size       int64
+	// Body is read twice, once to detect the mime type and once to store it.
+	Body io.ReadSeeker
+}
+
+// DetectMimeType sniffs the content type from the first bytes of the body,
+// clients are not trusted to tell it, and rewinds the body.
+func DetectMimeType(body io.ReadSeeker) (string, error) {
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("Err reading attachment: %v", err)
+	}
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("Err reading attachment: %v", err)
+	}
+
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return mimeType, nil
+}
+
+func IsImageMimeType(mimeType string) bool {
+	return imageMimeTypes[mimeType]
+}
+
+func attachmentKey(chatroomId, attachmentId uuid.UUID) string {
+	return path.Join("attachments", chatroomId.String(), attachmentId.String())
+}
+
+// Upload is the stored content of an attachment not sent yet.
+type Upload struct {
+	ID              uuid.UUID
+	Name            string
+	MimeType        string
+	Size            int64
+	Checksum        string
+	StorageKey      string
+	Width           sql.NullInt32
+	Height          sql.NullInt32
+	ThumbnailStatus string
+}
+
+// StoreAttachment checks the file and stores its content. It runs ahead of
+// SendAttachment so no transaction is held open during the upload.
+func StoreAttachment(params SendAttachmentParams, store storage.Store, ctx context.Context) (Upload, error) {
+
+	if params.Size > MaxAttachmentSize {
+		return Upload{}, ErrAttachmentTooLarge
+	}
+
+	name := path.Base(strings.ReplaceAll(params.Name, "\\", "/"))
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+
+	mimeType, err := DetectMimeType(params.Body)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	upload := Upload{
+		ID:              uuid.New(),
+		Name:            name,
+		MimeType:        mimeType,
+		Size:            params.Size,
+		ThumbnailStatus: thumbnail.StatusNone,
+	}
+
+	if IsImageMimeType(mimeType) {
+		config, err := thumbnail.Check(params.Body)
+		if err != nil {
+			return Upload{}, err
+		}
+		if _, err := params.Body.Seek(0, io.SeekStart); err != nil {
+			return Upload{}, fmt.Errorf("Err reading attachment: %v", err)
+		}
+
+		upload.ThumbnailStatus = thumbnail.StatusPending
+		upload.Width = sql.NullInt32{Int32: int32(config.Width), Valid: true}
+		upload.Height = sql.NullInt32{Int32: int32(config.Height), Valid: true}
+	}
+
+	upload.StorageKey = attachmentKey(params.ChatroomID, upload.ID)
+	hash := sha256.New()
+
+	err = store.Put(ctx, upload.StorageKey, io.TeeReader(params.Body, hash), params.Size, mimeType)
+	if err != nil {
+		return Upload{}, fmt.Errorf("Err storing attachment: %v", err)
+	}
+	upload.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	return upload, nil
+}
+
+// SendAttachment sends a stored attachment to the chatroom as an image or file
+// message. It writes both the message and the attachment, so dbq should be
+// bound to a transaction; the caller discards the upload when it fails.
+func SendAttachment(params SendAttachmentParams, upload Upload, ctx context.Context, dbq func() *db.Queries) (Message, error) {
+
+	msgType := MessageTypeFile
+	if IsImageMimeType(upload.MimeType) {
+		msgType = MessageTypeImage
+	}
+
+	msg, err := SendMessageInChatroom(SendMessageParams{
+		AuthorID:   params.AuthorID,
+		ChatroomID: params.ChatroomID,
+		Type:       msgType,
+		Content:    params.Caption,
+		ReplyToID:  params.ReplyToID,
+	}, ctx, dbq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	attachment, err := dbq().CreateAttachment(ctx, db.CreateAttachmentParams{
+		ID:              upload.ID,
+		MessageID:       msg.ID,
+		Name:            upload.Name,
+		MimeType:        upload.MimeType,
+		Size:            upload.Size,
+		Checksum:        upload.Checksum,
+		StorageKey:      upload.StorageKey,
+		Width:           upload.Width,
+		Height:          upload.Height,
+		ThumbnailStatus: upload.ThumbnailStatus,
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("Err creating attachment: %v", err)
+	}
+
+	msg.Attachment = NewAttachment(attachment, params.ChatroomID)
+
+	return msg, nil
+}
+
+// DiscardUpload deletes the content of an upload that could not be sent, when
+// the request context may already be gone.
+func DiscardUpload(upload Upload, store storage.Store) {
+	if err := store.Delete(context.Background(), upload.StorageKey); err != nil {
+		log.Printf("Err deleting orphaned attachment %s: %v", upload.StorageKey, err)
+	}
+}
+
+func findAttachmentInChatroom(attachmentId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (db.Attachment, error) {
+
+	attachment, err := dbq().FindAttachmentById(ctx, attachmentId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return db.Attachment{}, fmt.Errorf("Err finding attachment: %v", err)
+	}
+
+	msg, err := FindMessageInChatroom(attachment.MessageID, chatroomId, ctx, dbq)
+	if errors.Is(err, ErrMessageNotFound) || (err == nil && msg.Type == MessageTypeDeleted) {
+		return db.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return db.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// Download is the content of an attachment or thumbnail being served.
+type Download struct {
+	Name     string
+	MimeType string
+	// Size is 0 when unknown.
+	Size int64
+	Body io.ReadCloser
+}
+
+func openBlob(store storage.Store, key string, ctx context.Context) (io.ReadCloser, error) {
+
+	body, err := store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Err opening attachment: %v", err)
+	}
+
+	return body, nil
+}
+
+// OpenAttachment returns the content of an attachment of a message of the
+// chatroom. The caller must close its body.
+func OpenAttachment(attachmentId, chatroomId uuid.UUID, store storage.Store, ctx context.Context, dbq func() *db.Queries) (Download, error) {
+
+	attachment, err := findAttachmentInChatroom(attachmentId, chatroomId, ctx, dbq)
+	if err != nil {
+		return Download{}, err
+	}
+
+	body, err := openBlob(store, attachment.StorageKey, ctx)
+	if err != nil {
+		return Download{}, err
+	}
+
+	return Download{
+		Name:     attachment.Name,
+		MimeType: attachment.MimeType,
+		Size:     attachment.Size,
+		Body:     body,
+	}, nil
+}
+
+// OpenThumbnail is OpenAttachment for the JPEG thumbnail of an image.
+func OpenThumbnail(attachmentId, chatroomId uuid.UUID, store storage.Store, ctx context.Context, dbq func() *db.Queries) (Download, error) {
+
+	attachment, err := findAttachmentInChatroom(attachmentId, chatroomId, ctx, dbq)
+	if err != nil {
+		return Download{}, err
+	}
+
+	if attachment.ThumbnailStatus != thumbnail.StatusReady {
+		return Download{}, ErrAttachmentNotFound
+	}
+
+	body, err := openBlob(store, attachment.ThumbnailKey.String, ctx)
+	if err != nil {
+		return Download{}, err
+	}
+
+	return Download{
+		Name:     strings.TrimSuffix(attachment.Name, path.Ext(attachment.Name)) + "-thumbnail.jpg",
+		MimeType: "image/jpeg",
+		Body:     body,
+	}, nil
+}
+
+// DeleteAttachments removes the attachments of a message and returns the
+// storage keys of their content and thumbnails, to be deleted from the store
+// once the removal is committed.
+func DeleteAttachments(messageId uuid.UUID, ctx context.Context, dbq func() *db.Queries) ([]string, error) {
+
+	deleted, err := dbq().DeleteAttachmentsByMessageId(ctx, messageId)
+	if err != nil {
+		return nil, fmt.Errorf("Err deleting attachments: %v", err)
+	}
+
+	var keys []string
+	for _, a := range deleted {
+		keys = append(keys, a.StorageKey)
+		if a.ThumbnailKey.Valid {
+			keys = append(keys, a.ThumbnailKey.String)
+		}
+	}
+
+	return keys, nil
+}
+
+// DeleteStoredFiles removes the given keys from the store, carrying on past
+// failures and returning the first one.
+func DeleteStoredFiles(keys []string, store storage.Store, ctx context.Context) error {
+
+	var first error
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// attachAttachments fills in the attachments of a page of messages of one
+// chatroom.
+func attachAttachments(msgs []Message, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	from, to := msgs[0].SentAt, msgs[len(msgs)-1].SentAt
+	if from.After(to) {
+		from, to = to, from
+	}
+
+	attachments, err := dbq().FindAttachmentsByRoomBetween(ctx, db.FindAttachmentsByRoomBetweenParams{
+		ChatroomID: uuid.NullUUID{UUID: chatroomId, Valid: true},
+		SentAt:     from,
+		SentAt_2:   to,
+	})
+	if err != nil {
+		return fmt.Errorf("Err finding attachments: %v", err)
+	}
+
+	setAttachments(msgs, attachments, chatroomId)
+
+	return nil
+}
+
+// attachThreadAttachments fills in the attachments of a message and its
+// replies.
+func attachThreadAttachments(msgs []Message, parentId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	attachments, err := dbq().FindAttachmentsByThread(ctx, parentId)
+	if err != nil {
+		return fmt.Errorf("Err finding attachments: %v", err)
+	}
+
+	setAttachments(msgs, attachments, chatroomId)
+
+	return nil
+}
+
+func setAttachments(msgs []Message, attachments []db.Attachment, chatroomId uuid.UUID) {
+	byMessage := map[uuid.UUID]db.Attachment{}
+	for _, a := range attachments {
+		byMessage[a.MessageID] = a
+	}
+
+	for i := range msgs {
+		if a, ok := byMessage[msgs[i].ID]; ok {
+			msgs[i].Attachment = NewAttachment(a, chatroomId)
+		}
+	}
+}
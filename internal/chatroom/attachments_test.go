@@ -0,0 +1,46 @@
+package chatroom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name  string
+		body  []byte
+		want  string
+		image bool
+	}{
+		{"png", png, "image/png", true},
+		{"text", []byte("hello there"), "text/plain", false},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), "text/xml", false},
+		{"empty", []byte{}, "text/plain", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewReader(tt.body)
+
+			got, err := DetectMimeType(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectMimeType() = %q, want %q", got, tt.want)
+			}
+			if IsImageMimeType(got) != tt.image {
+				t.Errorf("IsImageMimeType(%q) = %v, want %v", got, !tt.image, tt.image)
+			}
+
+			// The body must be rewound for storing it.
+			rest, _ := io.ReadAll(body)
+			if !bytes.Equal(rest, tt.body) {
+				t.Errorf("body was not rewound")
+			}
+		})
+	}
+}
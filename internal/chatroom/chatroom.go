@@ -1,51 +1,361 @@
 package chatroom
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/presence"
 	"github.com/fernandofreamunde/ika/internal/user"
 	"github.com/google/uuid"
 )
 
+const (
+	TypeDirect = "direct"
+	TypeGroup  = "group"
+)
+
+var (
+	ErrDirectChatroom     = errors.New("Participants of a direct chatroom can not be changed.")
+	ErrAlreadyParticipant = errors.New("User already participates in the chatroom.")
+	ErrNotParticipant     = errors.New("User does not participate in the chatroom.")
+	ErrDirectSelf         = errors.New("Users can not open a direct chatroom with themselves.")
+	ErrNameRequired       = errors.New("Group chatrooms must have a name.")
+)
+
+// Chatroom is a chatroom as returned by the API.
+type Chatroom struct {
+	ID   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	// Name is null for direct chatrooms.
+	Name      *string   `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewChatroom(room db.Chatroom) Chatroom {
+	c := Chatroom{
+		ID:        room.ID,
+		Type:      room.Type,
+		CreatedAt: room.CreatedAt,
+		UpdatedAt: room.UpdatedAt,
+	}
+	if room.Name.Valid {
+		c.Name = &room.Name.String
+	}
+	return c
+}
+
 type SendMessageParams struct {
 	AuthorID uuid.UUID
 	ChatroomID uuid.UUID
+	// Type defaults to MessageTypeText.
+	Type string
+	// Content is optional for attachments, where it is the caption.
 	Content string
+	// ReplyToID optionally points at a message of the same chatroom.
+	ReplyToID uuid.NullUUID
 }
 
-func CreateChatRoomWithParticipants(p1, p2 user.User, ctx context.Context, dbq func() *db.Queries) (db.Chatroom, error) {
-	
+// directPair orders the users of a direct chatroom the way postgres orders
+// uuids, so each pair has a single key.
+func directPair(a, b uuid.UUID) (low, high uuid.UUID) {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		return b, a
+	}
+	return a, b
+}
+
+// GetOrCreateDirectChatroom returns the direct chatroom between p1 and p2,
+// creating it when p1 opens it for the first time. Either of them who left the
+// chatroom joins it again. It also tells if the chatroom was created.
+func GetOrCreateDirectChatroom(p1, p2 user.User, ctx context.Context, dbq func() *db.Queries) (db.Chatroom, bool, error) {
+
+	if p1.ID == p2.ID {
+		return db.Chatroom{}, false, ErrDirectSelf
+	}
+
+	blocked, err := user.IsBlockedBetween(p1.ID, p2.ID, ctx, dbq)
+	if err != nil {
+		return db.Chatroom{}, false, err
+	}
+	if blocked {
+		return db.Chatroom{}, false, user.ErrBlocked
+	}
+
+	if err := user.CanOpenDirectChatroom(p1.ID, p2.ID, ctx, dbq); err != nil {
+		return db.Chatroom{}, false, err
+	}
+
+	low, high := directPair(p1.ID, p2.ID)
+	pair := db.FindDirectChatroomParams{UserLowID: low, UserHighID: high}
+
+	room, err := dbq().FindDirectChatroom(ctx, pair)
+	if err == nil {
+		return room, false, joinDirectChatroom(room, []user.User{p1, p2}, ctx, dbq)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return db.Chatroom{}, false, fmt.Errorf("Err finding direct room: %v", err)
+	}
+
+	// Direct chatrooms have no name, clients show the other user instead.
+	room, err = dbq().CreateChatroom(ctx, db.CreateChatroomParams{
+		ID:   uuid.New(),
+		Type: TypeDirect,
+	})
+
+	if err != nil {
+		return db.Chatroom{}, false, fmt.Errorf("Err Creating room: %v", err)
+	}
+
+	claimed, err := dbq().ClaimDirectChatroom(ctx, db.ClaimDirectChatroomParams{
+		ChatroomID: room.ID,
+		UserLowID:  low,
+		UserHighID: high,
+	})
+	if err != nil {
+		return db.Chatroom{}, false, fmt.Errorf("Err claiming direct room: %v", err)
+	}
+
+	// Someone else created the pair's chatroom in the meantime, use theirs.
+	if claimed == 0 {
+		if err := dbq().DeleteChatroom(ctx, room.ID); err != nil {
+			return db.Chatroom{}, false, fmt.Errorf("Err deleting room: %v", err)
+		}
+
+		room, err = dbq().FindDirectChatroom(ctx, pair)
+		if err != nil {
+			return db.Chatroom{}, false, fmt.Errorf("Err finding direct room: %v", err)
+		}
+
+		return room, false, joinDirectChatroom(room, []user.User{p1, p2}, ctx, dbq)
+	}
+
+	return room, true, joinDirectChatroom(room, []user.User{p1, p2}, ctx, dbq)
+}
+
+// joinDirectChatroom adds the users who do not participate in the chatroom.
+func joinDirectChatroom(room db.Chatroom, users []user.User, ctx context.Context, dbq func() *db.Queries) error {
+
+	for _, u := range users {
+		in, err := IsUserParticipantInChatroom(u.ID, room.ID, ctx, dbq)
+		if err != nil {
+			return err
+		}
+		if in {
+			continue
+		}
+
+		err = dbq().ChatroomAddParticipant(ctx, db.ChatroomAddParticipantParams{
+			ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
+			ParticipantID: uuid.NullUUID{UUID: u.ID, Valid: true},
+			Role:          RoleMember,
+		})
+		if err != nil {
+			return fmt.Errorf("Err Adding participant to room: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// CreateGroupChatroom creates a named chatroom with the owner and the members
+// as participants.
+func CreateGroupChatroom(name string, owner user.User, members []user.User, ctx context.Context, dbq func() *db.Queries) (db.Chatroom, error) {
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.Chatroom{}, ErrNameRequired
+	}
+
 	room, err := dbq().CreateChatroom(ctx, db.CreateChatroomParams{
 		ID:   uuid.New(),
-		Name: sql.NullString{String: fmt.Sprintf("%s:%s", p1.Nickname, p2.Nickname), Valid: true},
-		Type: "direct",
+		Name: sql.NullString{String: name, Valid: true},
+		Type: TypeGroup,
 	})
 
 	if err != nil {
 		return db.Chatroom{}, fmt.Errorf("Err Creating room: %v", err)
 	}
 
+	added := map[uuid.UUID]bool{}
+	for _, p := range append([]user.User{owner}, members...) {
+		if added[p.ID] {
+			continue
+		}
+
+		role := RoleMember
+		if p.ID == owner.ID {
+			role = RoleOwner
+		}
+
+		err = dbq().ChatroomAddParticipant(ctx, db.ChatroomAddParticipantParams{
+			ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
+			ParticipantID: uuid.NullUUID{UUID: p.ID, Valid: true},
+			Role:          role,
+		})
+		if err != nil {
+			return db.Chatroom{}, fmt.Errorf("Err Adding participant to room: %v", err)
+		}
+		added[p.ID] = true
+	}
+
+	return room, nil
+}
+
+// AddParticipant adds the user to a group chatroom, which only its owner and
+// admins may do.
+func AddParticipant(room db.Chatroom, actorId uuid.UUID, u user.User, ctx context.Context, dbq func() *db.Queries) error {
+
+	if _, err := authorize(room, actorId, ActionAddParticipant, ctx, dbq); err != nil {
+		return err
+	}
+
+	in, err := IsUserParticipantInChatroom(u.ID, room.ID, ctx, dbq)
+	if err != nil {
+		return err
+	}
+	if in {
+		return ErrAlreadyParticipant
+	}
+
 	err = dbq().ChatroomAddParticipant(ctx, db.ChatroomAddParticipantParams{
 		ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
-		ParticipantID: uuid.NullUUID{UUID: p1.ID, Valid: true},
+		ParticipantID: uuid.NullUUID{UUID: u.ID, Valid: true},
+		Role:          RoleMember,
 	})
 	if err != nil {
-		return db.Chatroom{}, fmt.Errorf("Err Adding participant to room: %v", err)
+		return fmt.Errorf("Err Adding participant to room: %v", err)
 	}
 
-	err = dbq().ChatroomAddParticipant(ctx, db.ChatroomAddParticipantParams{
+	return nil
+}
+
+// RemoveParticipant removes the user from a group chatroom. Participants may
+// always remove themselves, removing someone else needs the kick permission
+// and a role above theirs. An owner leaving hands the chatroom over to the
+// first admin, or member when there is none, so it can still be moderated.
+func RemoveParticipant(room db.Chatroom, actorId, userId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if room.Type != TypeGroup {
+		return ErrDirectChatroom
+	}
+
+	targetRole, err := ParticipantRole(userId, room.ID, ctx, dbq)
+	if err != nil {
+		return err
+	}
+
+	if actorId != userId {
+		actorRole, err := authorize(room, actorId, ActionKick, ctx, dbq)
+		if err != nil {
+			return err
+		}
+		if rank[targetRole] >= rank[actorRole] {
+			return ErrForbidden
+		}
+	}
+
+	err = dbq().ChatroomRemoveParticipant(ctx, db.ChatroomRemoveParticipantParams{
 		ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
-		ParticipantID: uuid.NullUUID{UUID: p2.ID, Valid: true},
+		ParticipantID: uuid.NullUUID{UUID: userId, Valid: true},
 	})
+	if err != nil {
+		return fmt.Errorf("Err Removing participant from room: %v", err)
+	}
+
+	if targetRole == RoleOwner {
+		return handOver(room, ctx, dbq)
+	}
+
+	return nil
+}
+
+// handOver makes the next in line owner of the chatroom. Nobody is when the
+// chatroom is left empty.
+func handOver(room db.Chatroom, ctx context.Context, dbq func() *db.Queries) error {
 
+	successor, err := dbq().FindOwnerSuccessor(ctx, uuid.NullUUID{UUID: room.ID, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
-		return db.Chatroom{}, fmt.Errorf("Err Adding participant to room: %v", err)
+		return fmt.Errorf("Err finding new owner: %v", err)
 	}
 
-	return room, nil
+	err = dbq().UpdateParticipantRole(ctx, db.UpdateParticipantRoleParams{
+		Role:          RoleOwner,
+		ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
+		ParticipantID: successor,
+	})
+	if err != nil {
+		return fmt.Errorf("Err updating participant role: %v", err)
+	}
+
+	return nil
+}
+
+// LeaveChatroom removes the user from the chatroom, see RemoveParticipant for
+// group chatrooms.
+func LeaveChatroom(room db.Chatroom, userId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if room.Type == TypeGroup {
+		return RemoveParticipant(room, userId, userId, ctx, dbq)
+	}
+
+	err := dbq().ChatroomRemoveParticipant(ctx, db.ChatroomRemoveParticipantParams{
+		ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
+		ParticipantID: uuid.NullUUID{UUID: userId, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("Err leaving room: %v", err)
+	}
+
+	return nil
+}
+
+type Participant struct {
+	user.User
+	Role   string `json:"role"`
+	Typing bool   `json:"typing"`
+}
+
+func FindParticipants(chatroomId uuid.UUID, tracker *presence.Tracker, ctx context.Context, dbq func() *db.Queries) ([]Participant, error) {
+
+	rows, err := dbq().FindParticipantsByChatRoomId(ctx, uuid.NullUUID{UUID: chatroomId, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("Err finding participants: %v", err)
+	}
+
+	typing := map[uuid.UUID]bool{}
+	if tracker != nil {
+		for _, id := range tracker.Typing(chatroomId) {
+			typing[id] = true
+		}
+	}
+
+	participants := make([]Participant, 0, len(rows))
+	for _, u := range rows {
+		p := tracker.Lookup(u.ID)
+		participants = append(participants, Participant{
+			User: user.User{
+				ID:        u.ID,
+				Nickname:  u.Nickname,
+				CreatedAt: u.CreatedAt,
+				UpdatedAt: u.UpdatedAt,
+				Presence:  &p,
+			},
+			Role:   u.Role,
+			Typing: typing[u.ID],
+		})
+	}
+
+	return participants, nil
 }
 
 func IsUserParticipantInChatroom(userId uuid.UUID, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (bool, error) {
@@ -65,20 +375,82 @@ func IsUserParticipantInChatroom(userId uuid.UUID, chatroomId uuid.UUID, ctx con
 	return in, nil
 }
 
-func SendMessageInChatroom(params SendMessageParams, ctx context.Context, dbq func() *db.Queries) (db.Message, error) {
+// checkNotBlocked returns user.ErrBlocked when the chatroom is a direct one
+// and the other participant blocked the author.
+func checkNotBlocked(authorId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	room, err := dbq().FindChatRoomById(ctx, chatroomId)
+	if err != nil {
+		return fmt.Errorf("Err finding chatroom: %v", err)
+	}
+
+	if room.Type != TypeDirect {
+		return nil
+	}
+
+	participants, err := dbq().FindParticipantIdsByChatRoomId(ctx, uuid.NullUUID{UUID: room.ID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("Err finding participants: %v", err)
+	}
+
+	for _, p := range participants {
+		if p.ParticipantID.UUID == authorId {
+			continue
+		}
+		blocked, err := user.HasBlocked(p.ParticipantID.UUID, authorId, ctx, dbq)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return user.ErrBlocked
+		}
+	}
+
+	return nil
+}
+
+func SendMessageInChatroom(params SendMessageParams, ctx context.Context, dbq func() *db.Queries) (Message, error) {
+
+	// Only attachments may go without content.
+	if params.Content == "" && (params.Type == "" || params.Type == MessageTypeText) {
+		return Message{}, ErrEmptyContent
+	}
+
+	var preview *MessagePreview
+	if params.ReplyToID.Valid {
+		parent, err := dbq().FindMessageById(ctx, params.ReplyToID.UUID)
+		if err != nil {
+			return Message{}, ErrMessageNotFound
+		}
+		if parent.ChatroomID.UUID != params.ChatroomID {
+			return Message{}, ErrReplyOtherChatroom
+		}
+		preview = NewMessagePreview(parent.ID, parent.AuthorID.UUID, parent.Type, parent.Content.String)
+	}
+
+	if params.Type == "" {
+		params.Type = MessageTypeText
+	}
+
+	if err := checkNotBlocked(params.AuthorID, params.ChatroomID, ctx, dbq); err != nil {
+		return Message{}, err
+	}
 
 	msg, err := dbq().CreateMessage(ctx, db.CreateMessageParams{
 		ID:         uuid.New(),
-		Type:       "text",
+		Type:       params.Type,
 		AuthorID:   uuid.NullUUID{UUID: params.AuthorID, Valid: true},
 		ChatroomID: uuid.NullUUID{UUID: params.ChatroomID, Valid: true},
-		Content:    sql.NullString{String: params.Content, Valid: true},
+		Content:    sql.NullString{String: params.Content, Valid: params.Type == MessageTypeText || params.Content != ""},
+		ReplyToID:  params.ReplyToID,
 	})
 
 	if err != nil {
-		return db.Message{}, fmt.Errorf("Err creating message: %v", err)
+		return Message{}, fmt.Errorf("Err creating message: %v", err)
 	}
 
-	return msg, nil
-}
+	created := NewMessage(msg)
+	created.ReplyTo = preview
 
+	return created, nil
+}
@@ -0,0 +1,62 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/fernandofreamunde/ika/internal/user"
+	"github.com/google/uuid"
+)
+
+func TestDirectPairIsUnordered(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-0000000000ff")
+	b := uuid.MustParse("ff000000-0000-0000-0000-000000000000")
+
+	low, high := directPair(a, b)
+	if low != a || high != b {
+		t.Errorf("directPair(a, b) = %v, %v, want %v, %v", low, high, a, b)
+	}
+
+	low, high = directPair(b, a)
+	if low != a || high != b {
+		t.Errorf("directPair(b, a) = %v, %v, want %v, %v", low, high, a, b)
+	}
+}
+
+func TestGetOrCreateDirectChatroomRejectsSelf(t *testing.T) {
+	u := user.User{ID: uuid.New()}
+
+	// The check happens before touching the database.
+	_, _, err := GetOrCreateDirectChatroom(u, u, context.Background(), nil)
+	if !errors.Is(err, ErrDirectSelf) {
+		t.Fatalf("GetOrCreateDirectChatroom(self) = %v, want ErrDirectSelf", err)
+	}
+}
+
+func TestDirectChatroomsHaveNoName(t *testing.T) {
+	p1 := user.User{ID: uuid.New(), Nickname: "ika"}
+	p2 := user.User{ID: uuid.New(), Nickname: "tako"}
+
+	f := dbtest.New(map[string]dbtest.Result{
+		"IsBlockedBetween": dbtest.One(false),
+		"FindUserById": func(args []driver.Value) ([]string, [][]driver.Value) {
+			now := time.Now()
+			return dbtest.Rows([]driver.Value{args[0], now, now, "", "tako", "tako@example.com", false, true})
+		},
+		"CreateChatroom":   fakeResults["CreateChatroom"],
+		"FindChatRoomById": fakeResults["FindChatRoomById"],
+	})
+	defer f.Close()
+
+	room, created, err := GetOrCreateDirectChatroom(p1, p2, context.Background(), f.Queries)
+	if err != nil || !created {
+		t.Fatalf("GetOrCreateDirectChatroom = %v, %v, want a new chatroom", created, err)
+	}
+	if room.Name.Valid {
+		t.Errorf("direct chatroom is named %q, want no name", room.Name.String)
+	}
+}
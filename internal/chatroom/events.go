@@ -0,0 +1,112 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// Events are kept this long for event streams to catch up on.
+	EventRetention = 24 * time.Hour
+
+	// Clients that missed more events than this reload the chatroom instead.
+	MaxReplayedEvents = 500
+
+	replayPageSize = 100
+)
+
+var ErrEventsGone = errors.New("Missed events can no longer be replayed.")
+
+// RecordEvent adds the event to the chatroom's event log and returns its
+// number, which is one more than the previous event of the chatroom.
+func RecordEvent(chatroomId uuid.UUID, eventType string, resourceId uuid.UUID, data any, ctx context.Context, dbq func() *db.Queries) (int64, error) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("Err encoding event: %v", err)
+	}
+
+	event, err := dbq().CreateChatroomEvent(ctx, db.CreateChatroomEventParams{
+		ChatroomID: chatroomId,
+		Type:       eventType,
+		ResourceID: resourceId,
+		Data:       payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Err recording event: %v", err)
+	}
+
+	return event.Seq, nil
+}
+
+// LatestEventSeq returns the number of the chatroom's last event, 0 when it
+// has none.
+func LatestEventSeq(chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (int64, error) {
+
+	seq, err := dbq().FindChatroomEventSeq(ctx, chatroomId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Err finding latest event: %v", err)
+	}
+
+	return seq, nil
+}
+
+// FindMissedEvents returns the chatroom's events after seq, oldest first. It
+// returns ErrEventsGone when some of them were already pruned or there are
+// more than MaxReplayedEvents of them.
+func FindMissedEvents(chatroomId uuid.UUID, seq int64, ctx context.Context, dbq func() *db.Queries) ([]db.ChatroomEvent, error) {
+
+	latest, err := LatestEventSeq(chatroomId, ctx, dbq)
+	if err != nil {
+		return nil, err
+	}
+
+	if seq >= latest {
+		return nil, nil
+	}
+	if seq < 0 || latest-seq > MaxReplayedEvents {
+		return nil, ErrEventsGone
+	}
+
+	var events []db.ChatroomEvent
+	for seq < latest {
+		page, err := dbq().FindChatroomEventsAfter(ctx, db.FindChatroomEventsAfterParams{
+			ChatroomID: chatroomId,
+			Seq:        seq,
+			Limit:      replayPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Err finding missed events: %v", err)
+		}
+
+		if len(page) == 0 || page[0].Seq != seq+1 {
+			return nil, ErrEventsGone
+		}
+
+		events = append(events, page...)
+		seq = page[len(page)-1].Seq
+	}
+
+	return events, nil
+}
+
+// PruneEvents drops the events recorded before the given time.
+func PruneEvents(before time.Time, ctx context.Context, dbq func() *db.Queries) (int64, error) {
+
+	n, err := dbq().DeleteChatroomEventsBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("Err pruning events: %v", err)
+	}
+
+	return n, nil
+}
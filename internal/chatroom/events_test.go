@@ -0,0 +1,68 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+// eventLog fakes a chatroom whose events oldest to latest are still kept.
+func eventLog(oldest, latest int64) map[string]dbtest.Result {
+	return map[string]dbtest.Result{
+		"FindChatroomEventSeq": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Columns(1), [][]driver.Value{{latest}}
+		},
+		"FindChatroomEventsAfter": func(args []driver.Value) ([]string, [][]driver.Value) {
+			var rows [][]driver.Value
+			for seq := max(args[1].(int64)+1, oldest); seq <= latest && int64(len(rows)) < args[2].(int64); seq++ {
+				rows = append(rows, []driver.Value{args[0], seq, "message.updated", uuid.NewString(), []byte(`{}`), time.Now()})
+			}
+			return dbtest.Columns(6), rows
+		},
+	}
+}
+
+func TestFindMissedEvents(t *testing.T) {
+	room := uuid.New()
+
+	cases := []struct {
+		name           string
+		oldest, latest int64
+		after          int64
+		want           int
+		err            error
+	}{
+		{"up to date", 1, 40, 40, 0, nil},
+		{"over several pages", 1, 250, 10, 240, nil},
+		{"pruned", 30, 40, 10, 0, ErrEventsGone},
+		{"all pruned", 41, 40, 10, 0, ErrEventsGone},
+		{"too many", 1, MaxReplayedEvents + 20, 10, 0, ErrEventsGone},
+	}
+
+	for _, c := range cases {
+		f := dbtest.New(eventLog(c.oldest, c.latest))
+
+		events, err := FindMissedEvents(room, c.after, context.Background(), f.Queries)
+		f.Close()
+
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.err)
+			continue
+		}
+		if len(events) != c.want {
+			t.Errorf("%s: got %d events, want %d", c.name, len(events), c.want)
+			continue
+		}
+		for i, e := range events {
+			if e.Seq != c.after+int64(i)+1 {
+				t.Errorf("%s: event %d has seq %d, want %d", c.name, i, e.Seq, c.after+int64(i)+1)
+				break
+			}
+		}
+	}
+}
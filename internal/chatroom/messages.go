@@ -0,0 +1,333 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	MessageTypeText  = "text"
+	MessageTypeFile  = "file"
+	MessageTypeImage = "image"
+	// Deleted messages are kept as tombstones without content, so history
+	// and pagination cursors stay stable.
+	MessageTypeDeleted = "deleted"
+)
+
+// How many characters of the replied to message are quoted in a reply.
+const previewLength = 100
+
+var (
+	ErrMessageNotFound    = errors.New("Message not found.")
+	ErrMessageDeleted     = errors.New("Message has been deleted.")
+	ErrReplyOtherChatroom = errors.New("Replies must be in the same chatroom as the message they reply to.")
+	ErrEmptyContent       = errors.New("Message content can not be empty.")
+)
+
+// Message is a message as returned by the API.
+type Message struct {
+	ID         uuid.UUID `json:"id"`
+	ChatroomID uuid.UUID `json:"chatroom_id"`
+	AuthorID   uuid.UUID `json:"author_id"`
+	Type       string    `json:"type"`
+	// Content is empty for deleted messages and attachments without a caption.
+	Content   string     `json:"content"`
+	ReplyToID *uuid.UUID `json:"reply_to_id"`
+	SentAt    time.Time  `json:"sent_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Edited    bool       `json:"edited"`
+	Deleted   bool       `json:"deleted"`
+	// ReadBy is only filled in for direct chatrooms.
+	ReadBy     []uuid.UUID     `json:"read_by,omitempty"`
+	ReplyCount int64           `json:"reply_count"`
+	ReplyTo    *MessagePreview `json:"reply_to,omitempty"`
+	Reactions  []ReactionCount `json:"reactions"`
+	Attachment *Attachment     `json:"attachment,omitempty"`
+}
+
+// MessagePreview quotes the message a reply refers to.
+type MessagePreview struct {
+	ID       uuid.UUID `json:"id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	Content  string    `json:"content"`
+	Deleted  bool      `json:"deleted"`
+}
+
+func NewMessagePreview(id, authorId uuid.UUID, msgType string, content string) *MessagePreview {
+	preview := &MessagePreview{
+		ID:       id,
+		AuthorID: authorId,
+		Deleted:  msgType == MessageTypeDeleted,
+	}
+
+	runes := []rune(content)
+	if len(runes) > previewLength {
+		content = string(runes[:previewLength]) + "…"
+	}
+	if !preview.Deleted {
+		preview.Content = content
+	}
+
+	return preview
+}
+
+func newPageMessage(row db.FindMessagesByRoomByIdRow) Message {
+	msg := NewMessage(db.Message{
+		ID:         row.ID,
+		SentAt:     row.SentAt,
+		UpdatedAt:  row.UpdatedAt,
+		AuthorID:   row.AuthorID,
+		ChatroomID: row.ChatroomID,
+		Type:       row.Type,
+		Content:    row.Content,
+		ReplyToID:  row.ReplyToID,
+	})
+	msg.ReplyCount = row.ReplyCount
+
+	// A reply whose parent got hard deleted has no reply_to_id anymore.
+	if row.ReplyToID.Valid && row.ReplyToType.Valid {
+		msg.ReplyTo = NewMessagePreview(row.ReplyToID.UUID, row.ReplyToAuthorID.UUID, row.ReplyToType.String, row.ReplyToContent.String)
+	}
+
+	return msg
+}
+
+func NewMessage(m db.Message) Message {
+	deleted := m.Type == MessageTypeDeleted
+	msg := Message{
+		ID:         m.ID,
+		ChatroomID: m.ChatroomID.UUID,
+		AuthorID:   m.AuthorID.UUID,
+		Type:       m.Type,
+		Content:    m.Content.String,
+		SentAt:     m.SentAt,
+		UpdatedAt:  m.UpdatedAt,
+		Reactions:  []ReactionCount{},
+		// sent_at and updated_at are both NOW() on insert, so any later
+		// update means the content was edited.
+		Edited:  !deleted && m.UpdatedAt.After(m.SentAt),
+		Deleted: deleted,
+	}
+	if m.ReplyToID.Valid {
+		msg.ReplyToID = &m.ReplyToID.UUID
+	}
+	return msg
+}
+
+func NewMessages(msgs []db.Message) []Message {
+	out := make([]Message, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, NewMessage(m))
+	}
+	return out
+}
+
+type EditMessageParams struct {
+	EditorID   uuid.UUID
+	ChatroomID uuid.UUID
+	MessageID  uuid.UUID
+	Content    string
+}
+
+// FindMessageInChatroom returns the message, or ErrMessageNotFound when it
+// does not exist or belongs to another chatroom.
+func FindMessageInChatroom(messageId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (db.Message, error) {
+
+	msg, err := dbq().FindMessageById(ctx, messageId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && msg.ChatroomID.UUID != chatroomId) {
+		return db.Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return db.Message{}, fmt.Errorf("Err finding message: %v", err)
+	}
+
+	return msg, nil
+}
+
+// EditMessage replaces the content of a message written by the editor,
+// keeping the previous content as a revision.
+func EditMessage(params EditMessageParams, ctx context.Context, dbq func() *db.Queries) (Message, error) {
+
+	if params.Content == "" {
+		return Message{}, ErrEmptyContent
+	}
+
+	msg, err := FindMessageInChatroom(params.MessageID, params.ChatroomID, ctx, dbq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if msg.AuthorID.UUID != params.EditorID {
+		return Message{}, ErrForbidden
+	}
+
+	if msg.Type == MessageTypeDeleted {
+		return Message{}, ErrMessageDeleted
+	}
+
+	if err := saveRevision(msg, params.EditorID, ctx, dbq); err != nil {
+		return Message{}, err
+	}
+
+	updated, err := dbq().UpdateMessage(ctx, db.UpdateMessageParams{
+		Content: sql.NullString{String: params.Content, Valid: true},
+		ID:      msg.ID,
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("Err updating message: %v", err)
+	}
+
+	return NewMessage(updated), nil
+}
+
+// saveRevision keeps the current content of the message before the editor
+// changes it.
+func saveRevision(msg db.Message, editorId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	_, err := dbq().CreateMessageRevision(ctx, db.CreateMessageRevisionParams{
+		ID:        uuid.New(),
+		MessageID: msg.ID,
+		EditorID:  uuid.NullUUID{UUID: editorId, Valid: true},
+		Content:   msg.Content,
+	})
+	if err != nil {
+		return fmt.Errorf("Err saving message revision: %v", err)
+	}
+
+	return nil
+}
+
+// Revision is a previous content of a message.
+type Revision struct {
+	ID        uuid.UUID  `json:"id"`
+	MessageID uuid.UUID  `json:"message_id"`
+	EditorID  *uuid.UUID `json:"editor_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// FindMessageRevisions returns the previous contents of a message, oldest
+// first. Only the author and the chatroom's moderators may see them.
+func FindMessageRevisions(viewerId, messageId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) ([]Revision, error) {
+
+	msg, err := FindMessageInChatroom(messageId, chatroomId, ctx, dbq)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.AuthorID.UUID != viewerId {
+		role, err := ParticipantRole(viewerId, chatroomId, ctx, dbq)
+		if err != nil {
+			return nil, err
+		}
+		if !Can(role, ActionAudit) {
+			return nil, ErrForbidden
+		}
+	}
+
+	revisions, err := dbq().FindRevisionsByMessageId(ctx, msg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Err finding message revisions: %v", err)
+	}
+
+	result := make([]Revision, len(revisions))
+	for i, rev := range revisions {
+		result[i] = Revision{
+			ID:        rev.ID,
+			MessageID: rev.MessageID,
+			Content:   rev.Content.String,
+			CreatedAt: rev.CreatedAt,
+		}
+		if rev.EditorID.Valid {
+			result[i].EditorID = &rev.EditorID.UUID
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteMessage replaces the message with a tombstone. Its last content is
+// saved as a revision and kept with the others for moderators to audit.
+// Authors can delete their own messages, moderators anyone's.
+func DeleteMessage(actorId, messageId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (Message, error) {
+
+	msg, err := FindMessageInChatroom(messageId, chatroomId, ctx, dbq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if msg.Type == MessageTypeDeleted {
+		return Message{}, ErrMessageDeleted
+	}
+
+	if msg.AuthorID.UUID != actorId {
+		role, err := ParticipantRole(actorId, chatroomId, ctx, dbq)
+		if err != nil {
+			return Message{}, err
+		}
+		if !Can(role, ActionDeleteMessages) {
+			return Message{}, ErrForbidden
+		}
+	}
+
+	if err := saveRevision(msg, actorId, ctx, dbq); err != nil {
+		return Message{}, err
+	}
+
+	tombstone, err := dbq().TombstoneMessage(ctx, msg.ID)
+	if err != nil {
+		return Message{}, fmt.Errorf("Err deleting message: %v", err)
+	}
+
+	return NewMessage(tombstone), nil
+}
+
+type Thread struct {
+	Message Message   `json:"message"`
+	Replies []Message `json:"replies"`
+}
+
+// FindThread returns the message together with its replies, oldest first.
+func FindThread(messageId, chatroomId, viewerId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (Thread, error) {
+
+	parent, err := FindMessageInChatroom(messageId, chatroomId, ctx, dbq)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	replies, err := dbq().FindRepliesByMessageId(ctx, uuid.NullUUID{UUID: parent.ID, Valid: true})
+	if err != nil {
+		return Thread{}, fmt.Errorf("Err finding replies: %v", err)
+	}
+
+	thread := Thread{
+		Message: NewMessage(parent),
+		Replies: NewMessages(replies),
+	}
+
+	preview := NewMessagePreview(parent.ID, parent.AuthorID.UUID, parent.Type, parent.Content.String)
+	for i := range thread.Replies {
+		thread.Replies[i].ReplyTo = preview
+		if !thread.Replies[i].Deleted {
+			thread.Message.ReplyCount++
+		}
+	}
+
+	// Shown like in the message list, parent first.
+	msgs := append([]Message{thread.Message}, thread.Replies...)
+	if err := attachThreadReactions(msgs, parent.ID, viewerId, ctx, dbq); err != nil {
+		return Thread{}, err
+	}
+	if err := attachThreadAttachments(msgs, parent.ID, chatroomId, ctx, dbq); err != nil {
+		return Thread{}, err
+	}
+	thread.Message, thread.Replies = msgs[0], msgs[1:]
+
+	return thread, nil
+}
@@ -0,0 +1,76 @@
+package chatroom
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+func TestNewMessageEditedFlag(t *testing.T) {
+	sentAt := time.Now()
+
+	if NewMessage(db.Message{SentAt: sentAt, UpdatedAt: sentAt}).Edited {
+		t.Fatal("Fresh message is flagged as edited")
+	}
+
+	if !NewMessage(db.Message{SentAt: sentAt, UpdatedAt: sentAt.Add(time.Second)}).Edited {
+		t.Fatal("Updated message is not flagged as edited")
+	}
+}
+
+func TestNewMessageTombstone(t *testing.T) {
+	sentAt := time.Now()
+
+	msg := NewMessage(db.Message{Type: MessageTypeDeleted, SentAt: sentAt, UpdatedAt: sentAt.Add(time.Second)})
+	if !msg.Deleted {
+		t.Fatal("Tombstone is not flagged as deleted")
+	}
+	if msg.Edited {
+		t.Fatal("Tombstone is flagged as edited")
+	}
+}
+
+func TestMessagePreview(t *testing.T) {
+	long := strings.Repeat("ä", previewLength+10)
+
+	preview := NewMessagePreview(uuid.New(), uuid.New(), MessageTypeText, long)
+	if got := len([]rune(preview.Content)); got != previewLength+1 {
+		t.Fatalf("expected preview to be cut to %d characters plus ellipsis, got %d", previewLength, got)
+	}
+
+	preview = NewMessagePreview(uuid.New(), uuid.New(), MessageTypeDeleted, "")
+	if !preview.Deleted || preview.Content != "" {
+		t.Fatalf("expected preview of a tombstone to be empty and deleted, got %+v", preview)
+	}
+}
+
+func TestFindThreadHasReactionsAndAttachments(t *testing.T) {
+	roomID, author, viewer := uuid.New(), uuid.New(), uuid.New()
+	parentID, replyID := uuid.New(), uuid.New()
+	sentAt := time.Now()
+
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindMessageById":            dbtest.One(parentID.String(), sentAt, sentAt, author.String(), roomID.String(), MessageTypeText, "hello", nil),
+		"FindRepliesByMessageId":     dbtest.One(replyID.String(), sentAt, sentAt, viewer.String(), roomID.String(), MessageTypeText, "hi", parentID.String()),
+		"FindReactionCountsByThread": dbtest.One(parentID.String(), "👍", int64(2), true),
+		"FindAttachmentsByThread":    dbtest.One(uuid.NewString(), replyID.String(), "cat.png", "image/png", int64(3), "abc", "key", sentAt, nil, nil, "none", nil, nil, nil),
+	})
+	defer f.Close()
+
+	thread, err := FindThread(parentID, roomID, viewer, context.Background(), f.Queries)
+	if err != nil {
+		t.Fatalf("FindThread = %v", err)
+	}
+
+	if r := thread.Message.Reactions; len(r) != 1 || r[0].Emoji != "👍" || r[0].Count != 2 || !r[0].Reacted {
+		t.Errorf("parent reactions = %+v, want two 👍 including the viewer's", r)
+	}
+	if len(thread.Replies) != 1 || thread.Replies[0].Attachment == nil || thread.Replies[0].Attachment.Name != "cat.png" {
+		t.Errorf("replies = %+v, want the reply with its attachment", thread.Replies)
+	}
+}
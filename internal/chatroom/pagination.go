@@ -0,0 +1,151 @@
+package chatroom
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// Cursor points at a message by its position in the (sent_at, id) ordering.
+// Clients only ever see it encoded, so its format may change freely.
+type Cursor struct {
+	SentAt time.Time
+	ID     uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%s|%s", c.SentAt.UTC().Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	sentAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return Cursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, sentAt)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	return Cursor{SentAt: t, ID: u}, nil
+}
+
+type ListMessagesParams struct {
+	ChatroomID uuid.UUID
+	// ViewerID is the user listing the messages.
+	ViewerID uuid.UUID
+	// Before and After are mutually exclusive. Without either the newest
+	// messages are returned.
+	Before *Cursor
+	After  *Cursor
+	Limit  int
+}
+
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ListMessages returns a page of the chatroom's messages. Pages going back in
+// time (the default and Before) are ordered newest first, pages going forward
+// (After) oldest first; NextCursor continues in the same direction and is
+// empty once there is nothing left.
+func ListMessages(params ListMessagesParams, ctx context.Context, dbq func() *db.Queries) (MessagePage, error) {
+
+	if params.Before != nil && params.After != nil {
+		return MessagePage{}, fmt.Errorf("Only one of before and after can be set.")
+	}
+
+	if params.Limit <= 0 {
+		params.Limit = DefaultPageSize
+	}
+	if params.Limit > MaxPageSize {
+		params.Limit = MaxPageSize
+	}
+
+	// Fetch one extra row to know if there is another page.
+	limit := int32(params.Limit + 1)
+	roomID := uuid.NullUUID{UUID: params.ChatroomID, Valid: true}
+
+	// The three queries select the same columns, so their rows convert into
+	// one another.
+	var rows []db.FindMessagesByRoomByIdRow
+	var err error
+	switch {
+	case params.Before != nil:
+		var before []db.FindMessagesByRoomBeforeRow
+		before, err = dbq().FindMessagesByRoomBefore(ctx, db.FindMessagesByRoomBeforeParams{
+			ChatroomID: roomID,
+			SentAt:     params.Before.SentAt,
+			ID:         params.Before.ID,
+			Limit:      limit,
+		})
+		for _, row := range before {
+			rows = append(rows, db.FindMessagesByRoomByIdRow(row))
+		}
+	case params.After != nil:
+		var after []db.FindMessagesByRoomAfterRow
+		after, err = dbq().FindMessagesByRoomAfter(ctx, db.FindMessagesByRoomAfterParams{
+			ChatroomID: roomID,
+			SentAt:     params.After.SentAt,
+			ID:         params.After.ID,
+			Limit:      limit,
+		})
+		for _, row := range after {
+			rows = append(rows, db.FindMessagesByRoomByIdRow(row))
+		}
+	default:
+		rows, err = dbq().FindMessagesByRoomById(ctx, db.FindMessagesByRoomByIdParams{
+			ChatroomID: roomID,
+			Limit:      limit,
+		})
+	}
+
+	if err != nil {
+		return MessagePage{}, fmt.Errorf("Err finding messages: %v", err)
+	}
+
+	page := MessagePage{}
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		last := rows[params.Limit-1]
+		page.NextCursor = Cursor{SentAt: last.SentAt, ID: last.ID}.Encode()
+	}
+
+	page.Messages = make([]Message, 0, len(rows))
+	for _, row := range rows {
+		page.Messages = append(page.Messages, newPageMessage(row))
+	}
+
+	if err := attachReactions(page.Messages, params.ChatroomID, params.ViewerID, ctx, dbq); err != nil {
+		return MessagePage{}, err
+	}
+
+	if err := attachAttachments(page.Messages, params.ChatroomID, ctx, dbq); err != nil {
+		return MessagePage{}, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,32 @@
+package chatroom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{
+		SentAt: time.Date(2025, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:     uuid.New(),
+	}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+
+	if !decoded.SentAt.Equal(c.SentAt) || decoded.ID != c.ID {
+		t.Fatalf("Decoded cursor %+v differs from the encoded one %+v", decoded, c)
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "bm9waXBl", "MjAyNXxub3QtYS11dWlk"} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Fatalf("Cursor %q was accepted", s)
+		}
+	}
+}
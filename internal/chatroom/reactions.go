@@ -0,0 +1,168 @@
+package chatroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+// Emoji may be built from several code points (skin tones, ZWJ sequences, flags).
+const maxEmojiRunes = 16
+
+var ErrInvalidEmoji = errors.New("Reactions must be a single emoji.")
+
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int64  `json:"count"`
+	// Reacted tells if the user listing the messages is among those who reacted.
+	Reacted bool `json:"reacted"`
+}
+
+type Reaction struct {
+	MessageID uuid.UUID `json:"message_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+}
+
+type ReactionParams struct {
+	UserID     uuid.UUID
+	ChatroomID uuid.UUID
+	MessageID  uuid.UUID
+	Emoji      string
+}
+
+// ValidateEmoji rejects anything but a single emoji: a pictograph, keycap or
+// flag, possibly with a skin tone and joined to others by zero width joiners.
+func ValidateEmoji(emoji string) error {
+
+	n := utf8.RuneCountInString(emoji)
+	if n == 0 || n > maxEmojiRunes || !utf8.ValidString(emoji) {
+		return ErrInvalidEmoji
+	}
+
+	if !isEmojiSequence([]rune(emoji)) {
+		return ErrInvalidEmoji
+	}
+
+	return nil
+}
+
+func AddReaction(params ReactionParams, ctx context.Context, dbq func() *db.Queries) (Reaction, error) {
+
+	if err := ValidateEmoji(params.Emoji); err != nil {
+		return Reaction{}, err
+	}
+
+	msg, err := FindMessageInChatroom(params.MessageID, params.ChatroomID, ctx, dbq)
+	if err != nil {
+		return Reaction{}, err
+	}
+
+	if msg.Type == MessageTypeDeleted {
+		return Reaction{}, ErrMessageDeleted
+	}
+
+	err = dbq().AddMessageReaction(ctx, db.AddMessageReactionParams{
+		MessageID: msg.ID,
+		UserID:    params.UserID,
+		Emoji:     params.Emoji,
+	})
+	if err != nil {
+		return Reaction{}, fmt.Errorf("Err adding reaction: %v", err)
+	}
+
+	return Reaction{MessageID: msg.ID, UserID: params.UserID, Emoji: params.Emoji}, nil
+}
+
+func RemoveReaction(params ReactionParams, ctx context.Context, dbq func() *db.Queries) (Reaction, error) {
+
+	msg, err := FindMessageInChatroom(params.MessageID, params.ChatroomID, ctx, dbq)
+	if err != nil {
+		return Reaction{}, err
+	}
+
+	err = dbq().RemoveMessageReaction(ctx, db.RemoveMessageReactionParams{
+		MessageID: msg.ID,
+		UserID:    params.UserID,
+		Emoji:     params.Emoji,
+	})
+	if err != nil {
+		return Reaction{}, fmt.Errorf("Err removing reaction: %v", err)
+	}
+
+	return Reaction{MessageID: msg.ID, UserID: params.UserID, Emoji: params.Emoji}, nil
+}
+
+// attachReactions fills in the reaction counts of a page of messages of one
+// chatroom, as seen by the viewer.
+func attachReactions(msgs []Message, chatroomId, viewerId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	// Pages are sorted by sent_at, either way round.
+	from, to := msgs[0].SentAt, msgs[len(msgs)-1].SentAt
+	if from.After(to) {
+		from, to = to, from
+	}
+
+	rows, err := dbq().FindReactionCountsByRoomBetween(ctx, db.FindReactionCountsByRoomBetweenParams{
+		ChatroomID: uuid.NullUUID{UUID: chatroomId, Valid: true},
+		SentAt:     from,
+		SentAt_2:   to,
+		UserID:     viewerId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err finding reactions: %v", err)
+	}
+
+	counts := map[uuid.UUID][]ReactionCount{}
+	for _, row := range rows {
+		counts[row.MessageID] = append(counts[row.MessageID], ReactionCount{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted,
+		})
+	}
+	setReactions(msgs, counts)
+
+	return nil
+}
+
+// attachThreadReactions fills in the reaction counts of a message and its
+// replies, as seen by the viewer.
+func attachThreadReactions(msgs []Message, parentId, viewerId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	rows, err := dbq().FindReactionCountsByThread(ctx, db.FindReactionCountsByThreadParams{
+		ID:     parentId,
+		UserID: viewerId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err finding reactions: %v", err)
+	}
+
+	counts := map[uuid.UUID][]ReactionCount{}
+	for _, row := range rows {
+		counts[row.MessageID] = append(counts[row.MessageID], ReactionCount{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted,
+		})
+	}
+	setReactions(msgs, counts)
+
+	return nil
+}
+
+func setReactions(msgs []Message, counts map[uuid.UUID][]ReactionCount) {
+	for i := range msgs {
+		if c, ok := counts[msgs[i].ID]; ok {
+			msgs[i].Reactions = c
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package chatroom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		valid bool
+	}{
+		{"simple", "👍", true},
+		{"skin tone", "👍🏽", true},
+		{"zwj sequence", "👩‍💻", true},
+		{"flag", "🇵🇹", true},
+		{"heart", "❤️", true},
+		{"keycap", "1️⃣", true},
+		{"hash keycap", "#️⃣", true},
+		{"keycap without variant selector", "7\u20E3", true},
+		{"subdivision flag", "🏴\U000E0067\U000E0062\U000E0065\U000E006E\U000E0067\U000E007F", true},
+		{"zwj with variant selectors", "🏳️‍⚧️", true},
+		{"empty", "", false},
+		{"text", "lol", false},
+		{"digit", "1", false},
+		{"emoji with space", "👍 ", false},
+		{"plus", "+", false},
+		{"math symbol", "∑", false},
+		{"arrow symbol", "→", false},
+		{"lone regional indicator", "🇵", false},
+		{"lone skin tone", "🏽", false},
+		{"digit with variant selector", "1️", false},
+		{"unterminated tag sequence", "🏴\U000E0067\U000E0062", false},
+		{"two emoji", "👍👍", false},
+		{"trailing joiner", "👩‍", false},
+		{"too long", "👍👍👍👍👍👍👍👍👍👍👍👍👍👍👍👍👍", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEmoji(tt.emoji)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateEmoji(%q) = %v, want nil", tt.emoji, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidEmoji) {
+				t.Errorf("ValidateEmoji(%q) = %v, want ErrInvalidEmoji", tt.emoji, err)
+			}
+		})
+	}
+}
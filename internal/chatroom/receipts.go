@@ -0,0 +1,101 @@
+package chatroom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+// ChatroomSummary is a chatroom as listed for one of its participants.
+type ChatroomSummary struct {
+	Chatroom
+	LastReadMessageID *uuid.UUID `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	UnreadCount       int64      `json:"unread_count"`
+}
+
+func FindUsersChatrooms(userId uuid.UUID, ctx context.Context, dbq func() *db.Queries) ([]ChatroomSummary, error) {
+
+	rows, err := dbq().FindUsersChatroomsWithUnreadCount(ctx, uuid.NullUUID{UUID: userId, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("Err finding users rooms: %v", err)
+	}
+
+	rooms := make([]ChatroomSummary, 0, len(rows))
+	for _, row := range rows {
+		room := ChatroomSummary{
+			Chatroom: NewChatroom(db.Chatroom{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Type:      row.Type,
+				Name:      row.Name,
+			}),
+			UnreadCount: row.UnreadCount,
+		}
+		if row.LastReadMessageID.Valid {
+			room.LastReadMessageID = &row.LastReadMessageID.UUID
+		}
+		if row.LastReadAt.Valid {
+			room.LastReadAt = &row.LastReadAt.Time
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, nil
+}
+
+// MarkRead moves the user's read position in the chatroom up to the message.
+// Marking an older message than the current position is a no-op.
+func MarkRead(userId, chatroomId, messageId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if _, err := FindMessageInChatroom(messageId, chatroomId, ctx, dbq); err != nil {
+		return err
+	}
+
+	err := dbq().UpdateParticipantLastRead(ctx, db.UpdateParticipantLastReadParams{
+		LastReadMessageID: uuid.NullUUID{UUID: messageId, Valid: true},
+		ChatroomID:        uuid.NullUUID{UUID: chatroomId, Valid: true},
+		ParticipantID:     uuid.NullUUID{UUID: userId, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("Err marking chatroom read: %v", err)
+	}
+
+	return nil
+}
+
+// AttachReadReceipts fills in which participants, other than the author,
+// have read each message.
+func AttachReadReceipts(msgs []Message, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	positions, err := dbq().FindParticipantsReadPositions(ctx, uuid.NullUUID{UUID: chatroomId, Valid: true})
+	if err != nil {
+		return fmt.Errorf("Err finding read positions: %v", err)
+	}
+
+	for i := range msgs {
+		msgs[i].ReadBy = []uuid.UUID{}
+		for _, p := range positions {
+			if p.ParticipantID.UUID == msgs[i].AuthorID {
+				continue
+			}
+			if hasRead(Cursor{SentAt: p.SentAt, ID: p.ID}, msgs[i]) {
+				msgs[i].ReadBy = append(msgs[i].ReadBy, p.ParticipantID.UUID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasRead reports whether a reader positioned at the cursor has seen the message.
+func hasRead(position Cursor, m Message) bool {
+	if position.SentAt.Equal(m.SentAt) {
+		return position.ID.String() >= m.ID.String()
+	}
+	return position.SentAt.After(m.SentAt)
+}
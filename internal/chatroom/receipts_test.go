@@ -0,0 +1,31 @@
+package chatroom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHasRead(t *testing.T) {
+	now := time.Now()
+	msg := Message{ID: uuid.MustParse("55555555-5555-5555-5555-555555555555"), SentAt: now}
+
+	cases := []struct {
+		name     string
+		position Cursor
+		want     bool
+	}{
+		{"same message", Cursor{SentAt: now, ID: msg.ID}, true},
+		{"later message", Cursor{SentAt: now.Add(time.Second), ID: uuid.New()}, true},
+		{"earlier message", Cursor{SentAt: now.Add(-time.Second), ID: uuid.New()}, false},
+		{"same time, lower id", Cursor{SentAt: now, ID: uuid.MustParse("11111111-1111-1111-1111-111111111111")}, false},
+		{"same time, higher id", Cursor{SentAt: now, ID: uuid.MustParse("99999999-9999-9999-9999-999999999999")}, true},
+	}
+
+	for _, c := range cases {
+		if got := hasRead(c.position, msg); got != c.want {
+			t.Errorf("%s: hasRead = %v, expected %v", c.name, got, c.want)
+		}
+	}
+}
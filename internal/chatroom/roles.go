@@ -0,0 +1,197 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+var (
+	ErrForbidden   = errors.New("Your role in the chatroom does not allow this.")
+	ErrInvalidRole = errors.New("Role must be admin or member.")
+)
+
+// Action is something a participant may or may not do depending on their role.
+type Action int
+
+const (
+	ActionRename Action = iota
+	ActionAddParticipant
+	ActionKick
+	ActionPromote
+	ActionDelete
+	ActionAudit
+	ActionDeleteMessages
+)
+
+var permissions = map[string]map[Action]bool{
+	RoleOwner: {
+		ActionRename:         true,
+		ActionAddParticipant: true,
+		ActionKick:           true,
+		ActionPromote:        true,
+		ActionDelete:         true,
+		ActionAudit:          true,
+		ActionDeleteMessages: true,
+	},
+	RoleAdmin: {
+		ActionRename:         true,
+		ActionAddParticipant: true,
+		ActionKick:           true,
+		ActionPromote:        true,
+		ActionAudit:          true,
+		ActionDeleteMessages: true,
+	},
+	RoleMember: {},
+}
+
+// rank orders the roles, a participant can only act on those ranked below them.
+var rank = map[string]int{
+	RoleMember: 0,
+	RoleAdmin:  1,
+	RoleOwner:  2,
+}
+
+func Can(role string, action Action) bool {
+	return permissions[role][action]
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// ParticipantRole returns the role of the user in the chatroom, or
+// ErrNotParticipant when they do not participate in it.
+func ParticipantRole(userId, chatroomId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (string, error) {
+
+	role, err := dbq().FindParticipantRole(ctx, db.FindParticipantRoleParams{
+		ChatroomID:    uuid.NullUUID{UUID: chatroomId, Valid: true},
+		ParticipantID: uuid.NullUUID{UUID: userId, Valid: true},
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotParticipant
+	}
+	if err != nil {
+		return "", fmt.Errorf("Err finding participant role: %v", err)
+	}
+
+	return role, nil
+}
+
+// authorize checks the actor may perform the action in the group chatroom.
+func authorize(room db.Chatroom, actorId uuid.UUID, action Action, ctx context.Context, dbq func() *db.Queries) (string, error) {
+
+	if room.Type != TypeGroup {
+		return "", ErrDirectChatroom
+	}
+
+	role, err := ParticipantRole(actorId, room.ID, ctx, dbq)
+	if err != nil {
+		return "", err
+	}
+
+	if !Can(role, action) {
+		return "", ErrForbidden
+	}
+
+	return role, nil
+}
+
+func RenameChatroom(room db.Chatroom, actorId uuid.UUID, name string, ctx context.Context, dbq func() *db.Queries) (db.Chatroom, error) {
+
+	if _, err := authorize(room, actorId, ActionRename, ctx, dbq); err != nil {
+		return db.Chatroom{}, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.Chatroom{}, ErrNameRequired
+	}
+
+	updated, err := dbq().UpdateChatroom(ctx, db.UpdateChatroomParams{
+		Type: room.Type,
+		Name: sql.NullString{String: name, Valid: true},
+		ID:   room.ID,
+	})
+	if err != nil {
+		return db.Chatroom{}, fmt.Errorf("Err renaming room: %v", err)
+	}
+
+	return updated, nil
+}
+
+// SetParticipantRole changes the role of a participant. Nobody can touch the
+// owner, and admins can only hand out roles up to their own.
+func SetParticipantRole(room db.Chatroom, actorId, userId uuid.UUID, role string, ctx context.Context, dbq func() *db.Queries) error {
+
+	if !IsValidRole(role) || role == RoleOwner {
+		return ErrInvalidRole
+	}
+
+	actorRole, err := authorize(room, actorId, ActionPromote, ctx, dbq)
+	if err != nil {
+		return err
+	}
+
+	targetRole, err := ParticipantRole(userId, room.ID, ctx, dbq)
+	if err != nil {
+		return err
+	}
+
+	if actorId == userId || rank[targetRole] >= rank[actorRole] || rank[role] > rank[actorRole] {
+		return ErrForbidden
+	}
+
+	err = dbq().UpdateParticipantRole(ctx, db.UpdateParticipantRoleParams{
+		Role:          role,
+		ChatroomID:    uuid.NullUUID{UUID: room.ID, Valid: true},
+		ParticipantID: uuid.NullUUID{UUID: userId, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("Err updating participant role: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteChatroom deletes the chatroom with its messages and attachments. It
+// returns the storage keys of the attachments, to be deleted from the store
+// once the deletion is committed.
+func DeleteChatroom(room db.Chatroom, actorId uuid.UUID, ctx context.Context, dbq func() *db.Queries) ([]string, error) {
+
+	if _, err := authorize(room, actorId, ActionDelete, ctx, dbq); err != nil {
+		return nil, err
+	}
+
+	deleted, err := dbq().DeleteAttachmentsByChatroomId(ctx, uuid.NullUUID{UUID: room.ID, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("Err deleting attachments: %v", err)
+	}
+
+	var keys []string
+	for _, a := range deleted {
+		keys = append(keys, a.StorageKey)
+		if a.ThumbnailKey.Valid {
+			keys = append(keys, a.ThumbnailKey.String)
+		}
+	}
+
+	if err := dbq().DeleteChatroom(ctx, room.ID); err != nil {
+		return nil, fmt.Errorf("Err deleting room: %v", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,111 @@
+package chatroom
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/fernandofreamunde/ika/internal/user"
+	"github.com/google/uuid"
+)
+
+func TestPermissions(t *testing.T) {
+	cases := []struct {
+		role   string
+		action Action
+		want   bool
+	}{
+		{RoleOwner, ActionDelete, true},
+		{RoleOwner, ActionPromote, true},
+		{RoleAdmin, ActionRename, true},
+		{RoleAdmin, ActionKick, true},
+		{RoleAdmin, ActionDelete, false},
+		{RoleMember, ActionRename, false},
+		{RoleMember, ActionKick, false},
+		{"", ActionRename, false},
+	}
+
+	for _, c := range cases {
+		if got := Can(c.role, c.action); got != c.want {
+			t.Errorf("Can(%q, %d) = %v, expected %v", c.role, c.action, got, c.want)
+		}
+	}
+}
+
+func TestOwnerLeavingHandsOver(t *testing.T) {
+	owner, admin := uuid.New(), uuid.New()
+	room := db.Chatroom{ID: uuid.New(), Type: TypeGroup}
+
+	var promoted []driver.Value
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindParticipantRole": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Columns(1), [][]driver.Value{{RoleOwner}}
+		},
+		"FindOwnerSuccessor": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Columns(1), [][]driver.Value{{admin.String()}}
+		},
+		"UpdateParticipantRole": func(args []driver.Value) ([]string, [][]driver.Value) {
+			promoted = args
+			return nil, nil
+		},
+	})
+	defer f.Close()
+
+	if err := LeaveChatroom(room, owner, context.Background(), f.Queries); err != nil {
+		t.Fatalf("LeaveChatroom = %v", err)
+	}
+
+	if f.Calls("ChatroomRemoveParticipant") != 1 {
+		t.Errorf("owner was removed %d times, want once", f.Calls("ChatroomRemoveParticipant"))
+	}
+	if len(promoted) != 3 || promoted[0] != RoleOwner || promoted[2] != admin.String() {
+		t.Errorf("expected the admin to become owner, got %v", promoted)
+	}
+}
+
+func TestMembersCanNotAddParticipants(t *testing.T) {
+	room := db.Chatroom{ID: uuid.New(), Type: TypeGroup}
+
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindParticipantRole": dbtest.One(RoleMember),
+	})
+	defer f.Close()
+
+	err := AddParticipant(room, uuid.New(), user.User{ID: uuid.New()}, context.Background(), f.Queries)
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("AddParticipant = %v, expected ErrForbidden", err)
+	}
+	if f.Calls("ChatroomAddParticipant") != 0 {
+		t.Errorf("the participant was added")
+	}
+}
+
+func TestRenameChatroomTrimsName(t *testing.T) {
+	room := db.Chatroom{ID: uuid.New(), Type: TypeGroup}
+
+	var renamed []driver.Value
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindParticipantRole": dbtest.One(RoleOwner),
+		"UpdateChatroom": func(args []driver.Value) ([]string, [][]driver.Value) {
+			renamed = args
+			return dbtest.Rows([]driver.Value{room.ID.String(), time.Now(), time.Now(), room.Type, args[1]})
+		},
+	})
+	defer f.Close()
+
+	if _, err := RenameChatroom(room, uuid.New(), "   ", context.Background(), f.Queries); !errors.Is(err, ErrNameRequired) {
+		t.Errorf("RenameChatroom(%q) = %v, expected ErrNameRequired", "   ", err)
+	}
+
+	updated, err := RenameChatroom(room, uuid.New(), "  crew  ", context.Background(), f.Queries)
+	if err != nil {
+		t.Fatalf("RenameChatroom = %v", err)
+	}
+	if renamed[1] != "crew" || updated.Name.String != "crew" {
+		t.Errorf("expected the chatroom to be renamed to %q, got %v", "crew", renamed)
+	}
+}
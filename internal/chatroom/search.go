@@ -0,0 +1,147 @@
+package chatroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+// Markers the search queries put around matched words in snippets. The
+// queries strip them from message content first, so content can not fake a
+// highlight.
+const (
+	highlightStart = '\x02'
+	highlightStop  = '\x03'
+)
+
+var ErrEmptySearch = errors.New("Search query can not be empty.")
+
+// Highlight is a matched part of a snippet, as rune offsets into it.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type SearchResult struct {
+	MessageID  uuid.UUID `json:"message_id"`
+	ChatroomID uuid.UUID `json:"chatroom_id"`
+	AuthorID   uuid.UUID `json:"author_id"`
+	SentAt     time.Time `json:"sent_at"`
+	// Snippet is plain text, Highlights tell which parts matched so clients
+	// never have to render markup coming from message content.
+	Snippet    string      `json:"snippet"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type SearchMessagesParams struct {
+	UserID uuid.UUID
+	Query  string
+	Before *Cursor
+	Limit  int
+}
+
+// parseHighlights strips the highlight markers out of a snippet and returns
+// where they were.
+func parseHighlights(marked string) (string, []Highlight) {
+	var snippet strings.Builder
+	highlights := []Highlight{}
+
+	pos, start := 0, -1
+	for _, r := range marked {
+		switch {
+		case r == highlightStart:
+			start = pos
+		case r == highlightStop && start >= 0:
+			if pos > start {
+				highlights = append(highlights, Highlight{Start: start, End: pos})
+			}
+			start = -1
+		case r == highlightStop:
+		default:
+			snippet.WriteRune(r)
+			pos++
+		}
+	}
+
+	return snippet.String(), highlights
+}
+
+// SearchMessages finds messages matching the query in the chatrooms the user
+// participates in, newest first.
+func SearchMessages(params SearchMessagesParams, ctx context.Context, dbq func() *db.Queries) (SearchPage, error) {
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return SearchPage{}, ErrEmptySearch
+	}
+
+	if params.Limit <= 0 {
+		params.Limit = DefaultPageSize
+	}
+	if params.Limit > MaxPageSize {
+		params.Limit = MaxPageSize
+	}
+
+	// Fetch one extra row to know if there is another page.
+	limit := int32(params.Limit + 1)
+	userID := uuid.NullUUID{UUID: params.UserID, Valid: true}
+
+	// Both queries select the same columns.
+	var rows []db.SearchMessagesRow
+	var err error
+	if params.Before != nil {
+		var before []db.SearchMessagesBeforeRow
+		before, err = dbq().SearchMessagesBefore(ctx, db.SearchMessagesBeforeParams{
+			ParticipantID:      userID,
+			WebsearchToTsquery: params.Query,
+			SentAt:             params.Before.SentAt,
+			ID:                 params.Before.ID,
+			Limit:              limit,
+		})
+		for _, row := range before {
+			rows = append(rows, db.SearchMessagesRow(row))
+		}
+	} else {
+		rows, err = dbq().SearchMessages(ctx, db.SearchMessagesParams{
+			ParticipantID:      userID,
+			WebsearchToTsquery: params.Query,
+			Limit:              limit,
+		})
+	}
+
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("Err searching messages: %v", err)
+	}
+
+	page := SearchPage{}
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		last := rows[params.Limit-1]
+		page.NextCursor = Cursor{SentAt: last.SentAt, ID: last.ID}.Encode()
+	}
+
+	page.Results = make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		snippet, highlights := parseHighlights(row.Snippet)
+		page.Results = append(page.Results, SearchResult{
+			MessageID:  row.ID,
+			ChatroomID: row.ChatroomID.UUID,
+			AuthorID:   row.AuthorID.UUID,
+			SentAt:     row.SentAt,
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,33 @@
+package chatroom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHighlights(t *testing.T) {
+	tests := []struct {
+		name       string
+		marked     string
+		snippet    string
+		highlights []Highlight
+	}{
+		{"no match", "just text", "just text", []Highlight{}},
+		{"one match", "the \x02quick\x03 fox", "the quick fox", []Highlight{{4, 9}}},
+		{"two matches", "\x02a\x03 and \x02b\x03", "a and b", []Highlight{{0, 1}, {6, 7}}},
+		{"runes not bytes", "café \x02crème\x03", "café crème", []Highlight{{5, 10}}},
+		{"stray stop", "odd\x03 text", "odd text", []Highlight{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet, highlights := parseHighlights(tt.marked)
+			if snippet != tt.snippet {
+				t.Errorf("snippet = %q, want %q", snippet, tt.snippet)
+			}
+			if !reflect.DeepEqual(highlights, tt.highlights) {
+				t.Errorf("highlights = %v, want %v", highlights, tt.highlights)
+			}
+		})
+	}
+}
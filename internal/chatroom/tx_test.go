@@ -0,0 +1,199 @@
+package chatroom
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/fernandofreamunde/ika/internal/storage"
+	"github.com/fernandofreamunde/ika/internal/user"
+	"github.com/google/uuid"
+)
+
+// Rows for the queries writing chatrooms and messages, built from their
+// arguments like postgres would.
+var fakeResults = map[string]dbtest.Result{
+	"CreateChatroom": func(args []driver.Value) ([]string, [][]driver.Value) {
+		now := time.Now()
+		return dbtest.Columns(5), [][]driver.Value{{args[0], now, now, args[1], args[2]}}
+	},
+	"FindChatRoomById": func(args []driver.Value) ([]string, [][]driver.Value) {
+		now := time.Now()
+		return dbtest.Columns(5), [][]driver.Value{{args[0], now, now, TypeGroup, "room"}}
+	},
+	"CreateMessage": func(args []driver.Value) ([]string, [][]driver.Value) {
+		now := time.Now()
+		return dbtest.Columns(8), [][]driver.Value{{args[0], now, now, args[3], args[4], args[1], args[2], args[5]}}
+	},
+}
+
+func TestCreateGroupChatroomRollsBackMidway(t *testing.T) {
+	f := dbtest.New(fakeResults)
+	f.FailOn("ChatroomAddParticipant", 2)
+	defer f.Close()
+
+	owner, member := user.User{ID: uuid.New()}, user.User{ID: uuid.New()}
+	err := f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		_, err := CreateGroupChatroom("room", owner, []user.User{member}, context.Background(), dbq)
+		return err
+	})
+
+	if err == nil {
+		t.Fatalf("WithTx = nil, want the injected failure")
+	}
+	if f.Commits() != 0 || f.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+	}
+}
+
+func TestCreateGroupChatroomCommits(t *testing.T) {
+	f := dbtest.New(fakeResults)
+	defer f.Close()
+
+	owner, member := user.User{ID: uuid.New()}, user.User{ID: uuid.New()}
+	err := f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		_, err := CreateGroupChatroom("room", owner, []user.User{member}, context.Background(), dbq)
+		return err
+	})
+
+	if err != nil {
+		t.Fatalf("WithTx = %v", err)
+	}
+	if f.Commits() != 1 || f.Rollbacks() != 0 {
+		t.Errorf("commits = %d, rollbacks = %d, want 1 and 0", f.Commits(), f.Rollbacks())
+	}
+	if f.Calls("ChatroomAddParticipant") != 2 {
+		t.Errorf("added %d participants, want 2", f.Calls("ChatroomAddParticipant"))
+	}
+}
+
+func TestSendAttachmentRollsBackMessage(t *testing.T) {
+	f := dbtest.New(fakeResults)
+	f.FailOn("CreateAttachment", 1)
+	defer f.Close()
+
+	store := storage.NewLocalStore(t.TempDir())
+	params := SendAttachmentParams{
+		AuthorID:   uuid.New(),
+		ChatroomID: uuid.New(),
+		Name:       "notes.txt",
+		Size:       5,
+		Body:       bytes.NewReader([]byte("notes")),
+	}
+
+	upload, err := StoreAttachment(params, store, context.Background())
+	if err != nil {
+		t.Fatalf("StoreAttachment: %v", err)
+	}
+
+	err = f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		_, err := SendAttachment(params, upload, context.Background(), dbq)
+		return err
+	})
+	if err == nil {
+		t.Fatalf("WithTx = nil, want the injected failure")
+	}
+	DiscardUpload(upload, store)
+
+	if f.Calls("CreateMessage") != 1 {
+		t.Errorf("created %d messages, want 1 before the failure", f.Calls("CreateMessage"))
+	}
+	if f.Commits() != 0 || f.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+	}
+	if _, err := store.Get(context.Background(), upload.StorageKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get after discard = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRunInTxRollsBackOnPanic(t *testing.T) {
+	f := dbtest.New(fakeResults)
+	defer f.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected the panic to carry on")
+		}
+		if f.Commits() != 0 || f.Rollbacks() != 1 {
+			t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+		}
+	}()
+
+	f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		panic("boom")
+	})
+}
+
+func TestEditMessageRollsBackRevision(t *testing.T) {
+	author, room, message := uuid.New(), uuid.New(), uuid.New()
+
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindMessageById": func(args []driver.Value) ([]string, [][]driver.Value) {
+			now := time.Now()
+			return dbtest.Rows([]driver.Value{message.String(), now, now, author.String(), room.String(), MessageTypeText, "hello", nil})
+		},
+		"CreateMessageRevision": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], args[1], args[2], args[3], time.Now()})
+		},
+	})
+	f.FailOn("UpdateMessage", 1)
+	defer f.Close()
+
+	err := f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		_, err := EditMessage(EditMessageParams{
+			EditorID:   author,
+			ChatroomID: room,
+			MessageID:  message,
+			Content:    "hello again",
+		}, context.Background(), dbq)
+		return err
+	})
+
+	if err == nil {
+		t.Fatalf("WithTx = nil, want the injected failure")
+	}
+	if f.Calls("CreateMessageRevision") != 1 {
+		t.Errorf("saved %d revisions, want 1 before the failure", f.Calls("CreateMessageRevision"))
+	}
+	if f.Commits() != 0 || f.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+	}
+}
+
+func TestDeleteMessageSavesRevision(t *testing.T) {
+	author, room, message := uuid.New(), uuid.New(), uuid.New()
+
+	var saved []driver.Value
+	f := dbtest.New(map[string]dbtest.Result{
+		"FindMessageById": func(args []driver.Value) ([]string, [][]driver.Value) {
+			now := time.Now()
+			return dbtest.Rows([]driver.Value{message.String(), now, now, author.String(), room.String(), MessageTypeText, "hello", nil})
+		},
+		"CreateMessageRevision": func(args []driver.Value) ([]string, [][]driver.Value) {
+			saved = args
+			return dbtest.Rows([]driver.Value{args[0], args[1], args[2], args[3], time.Now()})
+		},
+	})
+	f.FailOn("TombstoneMessage", 1)
+	defer f.Close()
+
+	err := f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		_, err := DeleteMessage(author, message, room, context.Background(), dbq)
+		return err
+	})
+
+	if err == nil {
+		t.Fatalf("WithTx = nil, want the injected failure")
+	}
+	if len(saved) != 4 || saved[3] != "hello" {
+		t.Errorf("expected the content to be saved as a revision, got %v", saved)
+	}
+	if f.Commits() != 0 || f.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+	}
+}
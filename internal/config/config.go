@@ -0,0 +1,214 @@
+// Package config loads the settings of the API. Values come from, in order of
+// precedence, the environment, a .env file and an optional YAML file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Port        int    `yaml:"port"`
+	AppEnv      string `yaml:"app_env"`
+	AppSecret   string `yaml:"app_secret"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
+
+	Database Database `yaml:"database"`
+	Storage  Storage  `yaml:"storage"`
+}
+
+type Database struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Schema   string `yaml:"schema"`
+}
+
+type Storage struct {
+	// Driver is "local" or "s3".
+	Driver string `yaml:"driver"`
+	// Path is where the local driver keeps blobs.
+	Path string `yaml:"path"`
+	S3   S3     `yaml:"s3"`
+}
+
+type S3 struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// Default is the configuration before anything is loaded on top of it.
+func Default() Config {
+	return Config{
+		Port:   8080,
+		AppEnv: "local",
+		Database: Database{
+			Host:   "localhost",
+			Port:   5432,
+			Schema: "public",
+		},
+		Storage: Storage{
+			Driver: "local",
+			Path:   "storage",
+			S3:     S3{Region: "us-east-1"},
+		},
+	}
+}
+
+// Load reads the configuration and validates it.
+func Load(path string) (Config, error) {
+
+	cfg, err := Read(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Read reads the configuration without validating it, for commands that only
+// need part of it. The YAML file at path, or at CONFIG_FILE when path is
+// empty, is optional.
+func Read(path string) (Config, error) {
+
+	// Variables already in the environment win over the .env file.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("Err reading .env: %v", err)
+	}
+
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Err reading config file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("Err parsing config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overrides the settings that are set in the environment.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+
+	strs := map[string]*string{
+		"APP_ENV":               &c.AppEnv,
+		"APP_SECRET":            &c.AppSecret,
+		"BLUEPRINT_DB_HOST":     &c.Database.Host,
+		"BLUEPRINT_DB_DATABASE": &c.Database.Name,
+		"BLUEPRINT_DB_USERNAME": &c.Database.Username,
+		"BLUEPRINT_DB_PASSWORD": &c.Database.Password,
+		"BLUEPRINT_DB_SCHEMA":   &c.Database.Schema,
+		"STORAGE_DRIVER":        &c.Storage.Driver,
+		"STORAGE_PATH":          &c.Storage.Path,
+		"S3_ENDPOINT":           &c.Storage.S3.Endpoint,
+		"S3_REGION":             &c.Storage.S3.Region,
+		"S3_BUCKET":             &c.Storage.S3.Bucket,
+		"S3_ACCESS_KEY_ID":      &c.Storage.S3.AccessKeyID,
+		"S3_SECRET_ACCESS_KEY":  &c.Storage.S3.SecretAccessKey,
+	}
+	for name, field := range strs {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+
+	ints := map[string]*int{
+		"PORT":              &c.Port,
+		"BLUEPRINT_DB_PORT": &c.Database.Port,
+	}
+	for name, field := range ints {
+		if value, ok := lookup(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a number, got %q.", name, value)
+			}
+			*field = n
+		}
+	}
+
+	if value, ok := lookup("AUTO_MIGRATE"); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("AUTO_MIGRATE must be true or false, got %q.", value)
+		}
+		c.AutoMigrate = b
+	}
+
+	return nil
+}
+
+// Validate fails on settings the API can not start with.
+func (c Config) Validate() error {
+
+	if c.AppSecret == "" {
+		return errors.New("APP_SECRET must be set.")
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("PORT must be between 1 and 65535, got %d.", c.Port)
+	}
+
+	if err := c.Database.Validate(); err != nil {
+		return err
+	}
+
+	switch c.Storage.Driver {
+	case "local":
+		if c.Storage.Path == "" {
+			return errors.New("STORAGE_PATH must be set for the local storage driver.")
+		}
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			return errors.New("S3_ENDPOINT and S3_BUCKET must be set for the s3 storage driver.")
+		}
+	default:
+		return fmt.Errorf("STORAGE_DRIVER must be local or s3, got %q.", c.Storage.Driver)
+	}
+
+	return nil
+}
+
+// Validate fails on database settings nothing can connect with.
+func (d Database) Validate() error {
+
+	if d.Port < 1 || d.Port > 65535 {
+		return fmt.Errorf("BLUEPRINT_DB_PORT must be between 1 and 65535, got %d.", d.Port)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	cfg := Default()
+	err := cfg.loadEnv(lookupFrom(map[string]string{
+		"PORT":                  "9000",
+		"APP_SECRET":            "topSecret",
+		"BLUEPRINT_DB_DATABASE": "ika",
+		"BLUEPRINT_DB_PORT":     "6543",
+		"STORAGE_DRIVER":        "s3",
+		"S3_BUCKET":             "attachments",
+		"AUTO_MIGRATE":          "true",
+	}))
+	if err != nil {
+		t.Fatalf("loadEnv failed: %v", err)
+	}
+
+	if cfg.Port != 9000 || cfg.AppSecret != "topSecret" || !cfg.AutoMigrate {
+		t.Errorf("unexpected settings: %+v", cfg)
+	}
+	if cfg.Database.Name != "ika" || cfg.Database.Port != 6543 {
+		t.Errorf("unexpected database settings: %+v", cfg.Database)
+	}
+	// Unset variables keep their defaults.
+	if cfg.Database.Host != "localhost" || cfg.Storage.S3.Region != "us-east-1" {
+		t.Errorf("defaults were overridden: %+v", cfg)
+	}
+	if cfg.Storage.Driver != "s3" || cfg.Storage.S3.Bucket != "attachments" {
+		t.Errorf("unexpected storage settings: %+v", cfg.Storage)
+	}
+}
+
+func TestLoadEnvRejectsMalformedValues(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"PORT": "eighty"},
+		{"BLUEPRINT_DB_PORT": ""},
+		{"AUTO_MIGRATE": "sometimes"},
+	} {
+		cfg := Default()
+		if err := cfg.loadEnv(lookupFrom(env)); err == nil {
+			t.Errorf("expected an error for %v", env)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.AppSecret = "topSecret"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected the config to be valid; got %v", err)
+	}
+
+	cases := map[string]func(*Config){
+		"empty secret":    func(c *Config) { c.AppSecret = "" },
+		"port too low":    func(c *Config) { c.Port = 0 },
+		"port too high":   func(c *Config) { c.Port = 70000 },
+		"bad db port":     func(c *Config) { c.Database.Port = -1 },
+		"no storage path": func(c *Config) { c.Storage.Path = "" },
+		"s3 no bucket":    func(c *Config) { c.Storage.Driver = "s3"; c.Storage.S3.Endpoint = "http://minio" },
+		"unknown driver":  func(c *Config) { c.Storage.Driver = "ftp" },
+	}
+	for name, change := range cases {
+		cfg := valid
+		change(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadFileWithEnvPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ika.yml")
+	file := `port: 9000
+app_secret: fromFile
+database:
+  name: ika
+  port: 6543
+storage:
+  path: /var/lib/ika
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	t.Setenv("APP_SECRET", "fromEnv")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.AppSecret != "fromEnv" {
+		t.Errorf("expected the environment to win; got %q", cfg.AppSecret)
+	}
+	if cfg.Port != 9000 || cfg.Database.Name != "ika" || cfg.Database.Port != 6543 {
+		t.Errorf("file settings were not loaded: %+v", cfg)
+	}
+	if cfg.Storage.Path != "/var/lib/ika" || cfg.Storage.Driver != "local" {
+		t.Errorf("unexpected storage settings: %+v", cfg.Storage)
+	}
+}
+
+func TestLoadFailsWithoutSecret(t *testing.T) {
+	t.Setenv("APP_SECRET", "")
+
+	if _, err := Load(""); err == nil {
+		t.Fatal("expected Load to fail without APP_SECRET")
+	}
+}
+
+func TestReadSkipsValidation(t *testing.T) {
+	t.Setenv("APP_SECRET", "")
+
+	cfg, err := Read("")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		t.Errorf("expected the database settings to be valid; got %v", err)
+	}
+}
@@ -3,16 +3,16 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/fernandofreamunde/ika/internal/config"
 	"github.com/fernandofreamunde/ika/internal/db"
 	idb "github.com/fernandofreamunde/ika/internal/db"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
 )
 
 // Service represents a service that interacts with a database.
@@ -25,39 +25,35 @@ type Service interface {
 	// It returns an error if the connection cannot be closed.
 	Close() error
 	Queries() *db.Queries
+
+	// WithTx runs fn with queries bound to a single transaction, committed if
+	// fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(dbq func() *db.Queries) error) error
+
+	// Migrate runs one of the Migrate commands against the database.
+	Migrate(ctx context.Context, command string) error
 }
 
 type service struct {
-	db  *sql.DB
-	qdb *idb.Queries
+	db       *sql.DB
+	qdb      *idb.Queries
+	database string
 }
 
-var (
-	database   = os.Getenv("BLUEPRINT_DB_DATABASE")
-	password   = os.Getenv("BLUEPRINT_DB_PASSWORD")
-	username   = os.Getenv("BLUEPRINT_DB_USERNAME")
-	port       = os.Getenv("BLUEPRINT_DB_PORT")
-	host       = os.Getenv("BLUEPRINT_DB_HOST")
-	schema     = os.Getenv("BLUEPRINT_DB_SCHEMA")
-	dbInstance *service
-)
-
-func New() Service {
-	// Reuse Connection
-	if dbInstance != nil {
-		return dbInstance
-	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schema)
+// New opens a connection pool to the configured database. Every call opens
+// its own, so callers should share the one they got.
+func New(cfg config.Database) Service {
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable&search_path=%s", cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Name, cfg.Schema)
 	db, err := sql.Open("pgx", connStr)
 	adb := idb.New(db)
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbInstance = &service{
-		db:  db,
-		qdb: adb,
+	return &service{
+		db:       db,
+		qdb:      adb,
+		database: cfg.Name,
 	}
-	return dbInstance
 }
 
 // Health checks the health of the database connection by pinging the database.
@@ -116,10 +112,46 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", database)
+	log.Printf("Disconnected from database: %s", s.database)
 	return s.db.Close()
 }
 
 func (s *service) Queries() *db.Queries {
 	return s.qdb
 }
+
+func (s *service) WithTx(ctx context.Context, fn func(dbq func() *db.Queries) error) error {
+	return RunInTx(ctx, s.db, s.qdb, fn)
+}
+
+// RunInTx is WithTx for any connection and queries. Panics in fn roll the
+// transaction back before carrying on.
+func RunInTx(ctx context.Context, conn *sql.DB, q *db.Queries, fn func(dbq func() *db.Queries) error) error {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Err starting transaction: %v", err)
+	}
+
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("Err rolling back transaction: %v", err)
+		}
+	}()
+
+	txq := q.WithTx(tx)
+	if err := fn(func() *db.Queries { return txq }); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Err committing transaction: %v", err)
+	}
+	committed = true
+
+	return nil
+}
@@ -2,15 +2,24 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"log"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/fernandofreamunde/ika/internal/config"
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+// testConfig points at the postgres container started in TestMain.
+var testConfig config.Database
+
 func mustStartPostgresContainer() (func(context.Context, ...testcontainers.TerminateOption) error, error) {
 	var (
 		dbName = "database"
@@ -33,9 +42,12 @@ func mustStartPostgresContainer() (func(context.Context, ...testcontainers.Termi
 		return nil, err
 	}
 
-	database = dbName
-	password = dbPwd
-	username = dbUser
+	testConfig = config.Database{
+		Name:     dbName,
+		Username: dbUser,
+		Password: dbPwd,
+		Schema:   "public",
+	}
 
 	dbHost, err := dbContainer.Host(context.Background())
 	if err != nil {
@@ -47,8 +59,8 @@ func mustStartPostgresContainer() (func(context.Context, ...testcontainers.Termi
 		return dbContainer.Terminate, err
 	}
 
-	host = dbHost
-	port = dbPort.Port()
+	testConfig.Host = dbHost
+	testConfig.Port = dbPort.Int()
 
 	return dbContainer.Terminate, err
 }
@@ -59,6 +71,10 @@ func TestMain(m *testing.M) {
 		log.Fatalf("could not start postgres container: %v", err)
 	}
 
+	if err := New(testConfig).Migrate(context.Background(), MigrateUp); err != nil {
+		log.Fatalf("could not migrate the database: %v", err)
+	}
+
 	m.Run()
 
 	if teardown != nil && teardown(context.Background()) != nil {
@@ -67,14 +83,14 @@ func TestMain(m *testing.M) {
 }
 
 func TestNew(t *testing.T) {
-	srv := New()
+	srv := New(testConfig)
 	if srv == nil {
 		t.Fatal("New() returned nil")
 	}
 }
 
 func TestHealth(t *testing.T) {
-	srv := New()
+	srv := New(testConfig)
 
 	stats := srv.Health()
 
@@ -91,8 +107,120 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+func TestMigratedSchema(t *testing.T) {
+	srv := New(testConfig)
+
+	// Users are there from the first migration, direct chatrooms from one of
+	// the latest.
+	for _, table := range []string{"users", "direct_chatrooms"} {
+		var exists bool
+		err := srv.(*service).db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+		if err != nil {
+			t.Fatalf("error checking table %s: %v", table, err)
+		}
+		if !exists {
+			t.Errorf("expected table %s to be migrated", table)
+		}
+	}
+}
+
+// Message content holding the markers the search puts around matches must
+// not be able to fake a highlight.
+func TestSearchIgnoresHighlightMarkers(t *testing.T) {
+	ctx := context.Background()
+	q := New(testConfig).Queries()
+
+	u, err := q.CreateUser(ctx, db.CreateUserParams{
+		ID:             uuid.New(),
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: "hash",
+		Nickname:       "searcher-" + uuid.NewString()[:8],
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	room, err := q.CreateChatroom(ctx, db.CreateChatroomParams{
+		ID:   uuid.New(),
+		Type: "group",
+		Name: sql.NullString{String: "search", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateChatroom: %v", err)
+	}
+	userID := uuid.NullUUID{UUID: u.ID, Valid: true}
+	roomID := uuid.NullUUID{UUID: room.ID, Valid: true}
+	if err := q.ChatroomAddParticipant(ctx, db.ChatroomAddParticipantParams{ChatroomID: roomID, ParticipantID: userID, Role: "owner"}); err != nil {
+		t.Fatalf("ChatroomAddParticipant: %v", err)
+	}
+	_, err = q.CreateMessage(ctx, db.CreateMessageParams{
+		ID:         uuid.New(),
+		Type:       "text",
+		Content:    sql.NullString{String: "the \x02octopus\x03 hides from the squid", Valid: true},
+		AuthorID:   userID,
+		ChatroomID: roomID,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+
+	rows, err := q.SearchMessages(ctx, db.SearchMessagesParams{ParticipantID: userID, WebsearchToTsquery: "squid", Limit: 10})
+	if err != nil {
+		t.Fatalf("SearchMessages: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(rows))
+	}
+	snippet := rows[0].Snippet
+	if strings.Count(snippet, "\x02") != 1 || !strings.Contains(snippet, "\x02squid\x03") {
+		t.Errorf("expected only squid to be highlighted in %q", snippet)
+	}
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	srv := New(testConfig)
+
+	// The latest migration is undone, then replicas race to apply it again.
+	if err := srv.Migrate(context.Background(), MigrateDown); err != nil {
+		t.Fatalf("Migrate(down): %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- srv.Migrate(context.Background(), MigrateUp)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Migrate(up): %v", err)
+		}
+	}
+}
+
+func TestMigrateRedo(t *testing.T) {
+	srv := New(testConfig)
+
+	if err := srv.Migrate(context.Background(), MigrateRedo); err != nil {
+		t.Fatalf("Migrate(redo): %v", err)
+	}
+}
+
+func TestMigrateUnknownCommand(t *testing.T) {
+	srv := New(testConfig)
+
+	if err := srv.Migrate(context.Background(), "sideways"); err == nil {
+		t.Fatalf("expected an unknown command to fail")
+	}
+}
+
 func TestClose(t *testing.T) {
-	srv := New()
+	srv := New(testConfig)
 
 	if srv.Close() != nil {
 		t.Fatalf("expected Close() to return nil")
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/fernandofreamunde/ika/sql/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Migration commands, as given to the migrate subcommand.
+const (
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateStatus = "status"
+	MigrateRedo   = "redo"
+)
+
+// newMigrator returns a goose provider over the embedded migrations. It holds
+// a postgres advisory lock while migrating, so replicas starting together
+// apply them one at a time.
+func newMigrator(conn *sql.DB) (*goose.Provider, error) {
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("Err creating migration lock: %v", err)
+	}
+
+	migrator, err := goose.NewProvider(goose.DialectPostgres, conn, schema.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("Err loading migrations: %v", err)
+	}
+
+	return migrator, nil
+}
+
+func (s *service) Migrate(ctx context.Context, command string) error {
+	return Migrate(ctx, s.db, command)
+}
+
+// Migrate runs one of the migration commands against the connection and logs
+// what it did.
+func Migrate(ctx context.Context, conn *sql.DB, command string) error {
+
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case MigrateUp:
+		results, err := migrator.Up(ctx)
+		logMigrations(results...)
+		if err != nil {
+			return fmt.Errorf("Err migrating up: %v", err)
+		}
+		if len(results) == 0 {
+			log.Printf("Database schema is up to date.")
+		}
+
+	case MigrateDown:
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("Err migrating down: %v", err)
+		}
+		logMigrations(result)
+
+	case MigrateRedo:
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("Err migrating down: %v", err)
+		}
+		logMigrations(result)
+
+		result, err = migrator.UpByOne(ctx)
+		if err != nil {
+			return fmt.Errorf("Err migrating up: %v", err)
+		}
+		logMigrations(result)
+
+	case MigrateStatus:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("Err getting migration status: %v", err)
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.State == goose.StateApplied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			log.Printf("%-20s %s", appliedAt, s.Source.Path)
+		}
+
+	default:
+		return fmt.Errorf("Unknown migrate command %q, use up, down, status or redo.", command)
+	}
+
+	return nil
+}
+
+func logMigrations(results ...*goose.MigrationResult) {
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		log.Printf("Migrated %s %s in %v", r.Direction, r.Source.Path, r.Duration)
+	}
+}
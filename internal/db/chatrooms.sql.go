@@ -8,22 +8,24 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const chatroomAddParticipant = `-- name: ChatroomAddParticipant :exec
-INSERT INTO chatrooms_participants(chatroom_id, participant_id)
-VALUES ($1, $2)
+INSERT INTO chatrooms_participants(chatroom_id, participant_id, role)
+VALUES ($1, $2, $3)
 `
 
 type ChatroomAddParticipantParams struct {
 	ChatroomID    uuid.NullUUID
 	ParticipantID uuid.NullUUID
+	Role          string
 }
 
 func (q *Queries) ChatroomAddParticipant(ctx context.Context, arg ChatroomAddParticipantParams) error {
-	_, err := q.db.ExecContext(ctx, chatroomAddParticipant, arg.ChatroomID, arg.ParticipantID)
+	_, err := q.db.ExecContext(ctx, chatroomAddParticipant, arg.ChatroomID, arg.ParticipantID, arg.Role)
 	return err
 }
 
@@ -42,6 +44,26 @@ func (q *Queries) ChatroomRemoveParticipant(ctx context.Context, arg ChatroomRem
 	return err
 }
 
+const claimDirectChatroom = `-- name: ClaimDirectChatroom :execrows
+INSERT INTO direct_chatrooms (chatroom_id, user_low_id, user_high_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type ClaimDirectChatroomParams struct {
+	ChatroomID uuid.UUID
+	UserLowID  uuid.UUID
+	UserHighID uuid.UUID
+}
+
+func (q *Queries) ClaimDirectChatroom(ctx context.Context, arg ClaimDirectChatroomParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimDirectChatroom, arg.ChatroomID, arg.UserLowID, arg.UserHighID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createChatroom = `-- name: CreateChatroom :one
 INSERT INTO chatrooms (id, type, name, created_at, updated_at)
 VALUES ($1, $2, $3, NOW(), NOW())
@@ -93,8 +115,47 @@ func (q *Queries) FindChatRoomById(ctx context.Context, id uuid.UUID) (Chatroom,
 	return i, err
 }
 
+const findDirectChatroom = `-- name: FindDirectChatroom :one
+SELECT cr.id, cr.created_at, cr.updated_at, cr.type, cr.name FROM chatrooms AS cr
+INNER JOIN direct_chatrooms AS dc ON dc.chatroom_id = cr.id
+WHERE dc.user_low_id = $1 AND dc.user_high_id = $2
+`
+
+type FindDirectChatroomParams struct {
+	UserLowID  uuid.UUID
+	UserHighID uuid.UUID
+}
+
+func (q *Queries) FindDirectChatroom(ctx context.Context, arg FindDirectChatroomParams) (Chatroom, error) {
+	row := q.db.QueryRowContext(ctx, findDirectChatroom, arg.UserLowID, arg.UserHighID)
+	var i Chatroom
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Type,
+		&i.Name,
+	)
+	return i, err
+}
+
+const findOwnerSuccessor = `-- name: FindOwnerSuccessor :one
+SELECT participant_id FROM chatrooms_participants
+WHERE chatroom_id = $1 AND role <> 'owner'
+ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at ASC, participant_id ASC
+LIMIT 1
+`
+
+// Admins come first, then whoever joined the chatroom first.
+func (q *Queries) FindOwnerSuccessor(ctx context.Context, chatroomID uuid.NullUUID) (uuid.NullUUID, error) {
+	row := q.db.QueryRowContext(ctx, findOwnerSuccessor, chatroomID)
+	var participant_id uuid.NullUUID
+	err := row.Scan(&participant_id)
+	return participant_id, err
+}
+
 const findParticipantIdsByChatRoomId = `-- name: FindParticipantIdsByChatRoomId :many
-SELECT chatroom_id, participant_id FROM chatrooms_participants WHERE chatroom_id = $1
+SELECT chatroom_id, participant_id, role, last_read_message_id, last_read_at, joined_at FROM chatrooms_participants WHERE chatroom_id = $1
 `
 
 func (q *Queries) FindParticipantIdsByChatRoomId(ctx context.Context, chatroomID uuid.NullUUID) ([]ChatroomsParticipant, error) {
@@ -106,7 +167,118 @@ func (q *Queries) FindParticipantIdsByChatRoomId(ctx context.Context, chatroomID
 	var items []ChatroomsParticipant
 	for rows.Next() {
 		var i ChatroomsParticipant
-		if err := rows.Scan(&i.ChatroomID, &i.ParticipantID); err != nil {
+		if err := rows.Scan(
+			&i.ChatroomID,
+			&i.ParticipantID,
+			&i.Role,
+			&i.LastReadMessageID,
+			&i.LastReadAt,
+			&i.JoinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findParticipantRole = `-- name: FindParticipantRole :one
+SELECT role FROM chatrooms_participants
+WHERE chatroom_id = $1 AND participant_id = $2
+`
+
+type FindParticipantRoleParams struct {
+	ChatroomID    uuid.NullUUID
+	ParticipantID uuid.NullUUID
+}
+
+func (q *Queries) FindParticipantRole(ctx context.Context, arg FindParticipantRoleParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, findParticipantRole, arg.ChatroomID, arg.ParticipantID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const findParticipantsByChatRoomId = `-- name: FindParticipantsByChatRoomId :many
+SELECT u.id, u.created_at, u.updated_at, u.hashed_password, u.nickname, u.email, u.contacts_only, u.discoverable, cp.role FROM users AS u
+INNER JOIN chatrooms_participants AS cp ON u.id = cp.participant_id
+WHERE cp.chatroom_id = $1
+`
+
+type FindParticipantsByChatRoomIdRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HashedPassword string
+	Nickname       string
+	Email          string
+	ContactsOnly   bool
+	Discoverable   bool
+	Role           string
+}
+
+func (q *Queries) FindParticipantsByChatRoomId(ctx context.Context, chatroomID uuid.NullUUID) ([]FindParticipantsByChatRoomIdRow, error) {
+	rows, err := q.db.QueryContext(ctx, findParticipantsByChatRoomId, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindParticipantsByChatRoomIdRow
+	for rows.Next() {
+		var i FindParticipantsByChatRoomIdRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.Nickname,
+			&i.Email,
+			&i.ContactsOnly,
+			&i.Discoverable,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findParticipantsReadPositions = `-- name: FindParticipantsReadPositions :many
+SELECT cp.participant_id, m.sent_at, m.id
+FROM chatrooms_participants AS cp
+INNER JOIN messages AS m ON m.id = cp.last_read_message_id
+WHERE cp.chatroom_id = $1
+`
+
+type FindParticipantsReadPositionsRow struct {
+	ParticipantID uuid.NullUUID
+	SentAt        time.Time
+	ID            uuid.UUID
+}
+
+func (q *Queries) FindParticipantsReadPositions(ctx context.Context, chatroomID uuid.NullUUID) ([]FindParticipantsReadPositionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, findParticipantsReadPositions, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindParticipantsReadPositionsRow
+	for rows.Next() {
+		var i FindParticipantsReadPositionsRow
+		if err := rows.Scan(&i.ParticipantID, &i.SentAt, &i.ID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -155,6 +327,84 @@ func (q *Queries) FindUsersChatrooms(ctx context.Context, participantID uuid.Nul
 	return items, nil
 }
 
+const findUsersChatroomsWithUnreadCount = `-- name: FindUsersChatroomsWithUnreadCount :many
+SELECT cr.id, cr.created_at, cr.updated_at, cr.type, cr.name, cp.last_read_message_id, cp.last_read_at,
+	(
+		SELECT COUNT(*) FROM messages AS m
+		LEFT JOIN messages AS lr ON lr.id = cp.last_read_message_id
+		WHERE m.chatroom_id = cr.id
+		AND m.author_id IS DISTINCT FROM cp.participant_id
+		AND m.type <> 'deleted'
+		AND (lr.id IS NULL OR (m.sent_at, m.id) > (lr.sent_at, lr.id))
+	) AS unread_count
+FROM chatrooms AS cr
+INNER JOIN chatrooms_participants AS cp ON cr.id = cp.chatroom_id
+WHERE cp.participant_id = $1
+`
+
+type FindUsersChatroomsWithUnreadCountRow struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Type              string
+	Name              sql.NullString
+	LastReadMessageID uuid.NullUUID
+	LastReadAt        sql.NullTime
+	UnreadCount       int64
+}
+
+func (q *Queries) FindUsersChatroomsWithUnreadCount(ctx context.Context, participantID uuid.NullUUID) ([]FindUsersChatroomsWithUnreadCountRow, error) {
+	rows, err := q.db.QueryContext(ctx, findUsersChatroomsWithUnreadCount, participantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindUsersChatroomsWithUnreadCountRow
+	for rows.Next() {
+		var i FindUsersChatroomsWithUnreadCountRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Type,
+			&i.Name,
+			&i.LastReadMessageID,
+			&i.LastReadAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const shareChatroom = `-- name: ShareChatroom :one
+SELECT EXISTS(
+	SELECT 1 FROM chatrooms_participants AS a
+	INNER JOIN chatrooms_participants AS b ON b.chatroom_id = a.chatroom_id
+	WHERE a.participant_id = $1 AND b.participant_id = $2
+)
+`
+
+type ShareChatroomParams struct {
+	ParticipantID   uuid.NullUUID
+	ParticipantID_2 uuid.NullUUID
+}
+
+func (q *Queries) ShareChatroom(ctx context.Context, arg ShareChatroomParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, shareChatroom, arg.ParticipantID, arg.ParticipantID_2)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const updateChatroom = `-- name: UpdateChatroom :one
 UPDATE chatrooms
 SET type = $1, name = $2, updated_at = NOW()
@@ -180,3 +430,42 @@ func (q *Queries) UpdateChatroom(ctx context.Context, arg UpdateChatroomParams)
 	)
 	return i, err
 }
+
+const updateParticipantLastRead = `-- name: UpdateParticipantLastRead :exec
+UPDATE chatrooms_participants AS cp
+SET last_read_message_id = $1, last_read_at = NOW()
+WHERE cp.chatroom_id = $2 AND cp.participant_id = $3
+AND NOT EXISTS (
+	SELECT 1 FROM messages AS cur, messages AS new
+	WHERE cur.id = cp.last_read_message_id AND new.id = $1
+	AND (cur.sent_at, cur.id) >= (new.sent_at, new.id)
+)
+`
+
+type UpdateParticipantLastReadParams struct {
+	LastReadMessageID uuid.NullUUID
+	ChatroomID        uuid.NullUUID
+	ParticipantID     uuid.NullUUID
+}
+
+func (q *Queries) UpdateParticipantLastRead(ctx context.Context, arg UpdateParticipantLastReadParams) error {
+	_, err := q.db.ExecContext(ctx, updateParticipantLastRead, arg.LastReadMessageID, arg.ChatroomID, arg.ParticipantID)
+	return err
+}
+
+const updateParticipantRole = `-- name: UpdateParticipantRole :exec
+UPDATE chatrooms_participants
+SET role = $1
+WHERE chatroom_id = $2 AND participant_id = $3
+`
+
+type UpdateParticipantRoleParams struct {
+	Role          string
+	ChatroomID    uuid.NullUUID
+	ParticipantID uuid.NullUUID
+}
+
+func (q *Queries) UpdateParticipantRole(ctx context.Context, arg UpdateParticipantRoleParams) error {
+	_, err := q.db.ExecContext(ctx, updateParticipantRole, arg.Role, arg.ChatroomID, arg.ParticipantID)
+	return err
+}
@@ -8,14 +8,15 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (id, type, content, author_id, chatroom_id, sent_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-RETURNING id, sent_at, updated_at, author_id, chatroom_id, type, content
+INSERT INTO messages (id, type, content, author_id, chatroom_id, reply_to_id, sent_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+RETURNING id, sent_at, updated_at, author_id, chatroom_id, type, content, reply_to_id
 `
 
 type CreateMessageParams struct {
@@ -24,6 +25,7 @@ type CreateMessageParams struct {
 	Content    sql.NullString
 	AuthorID   uuid.NullUUID
 	ChatroomID uuid.NullUUID
+	ReplyToID  uuid.NullUUID
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -33,6 +35,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.Content,
 		arg.AuthorID,
 		arg.ChatroomID,
+		arg.ReplyToID,
 	)
 	var i Message
 	err := row.Scan(
@@ -43,6 +46,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.ChatroomID,
 		&i.Type,
 		&i.Content,
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -56,15 +60,252 @@ func (q *Queries) DeleteMessage(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const findMessageById = `-- name: FindMessageById :one
+SELECT id, sent_at, updated_at, author_id, chatroom_id, type, content, reply_to_id FROM messages WHERE id = $1
+`
+
+func (q *Queries) FindMessageById(ctx context.Context, id uuid.UUID) (Message, error) {
+	row := q.db.QueryRowContext(ctx, findMessageById, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SentAt,
+		&i.UpdatedAt,
+		&i.AuthorID,
+		&i.ChatroomID,
+		&i.Type,
+		&i.Content,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
+const findMessagesByRoomAfter = `-- name: FindMessagesByRoomAfter :many
+SELECT m.id, m.sent_at, m.updated_at, m.author_id, m.chatroom_id, m.type, m.content, m.reply_to_id,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+AND (m.sent_at > $2 OR (m.sent_at = $2 AND m.id > $3))
+ORDER BY m.sent_at ASC, m.id ASC
+LIMIT $4
+`
+
+type FindMessagesByRoomAfterParams struct {
+	ChatroomID uuid.NullUUID
+	SentAt     time.Time
+	ID         uuid.UUID
+	Limit      int32
+}
+
+type FindMessagesByRoomAfterRow struct {
+	ID              uuid.UUID
+	SentAt          time.Time
+	UpdatedAt       time.Time
+	AuthorID        uuid.NullUUID
+	ChatroomID      uuid.NullUUID
+	Type            string
+	Content         sql.NullString
+	ReplyToID       uuid.NullUUID
+	ReplyCount      int64
+	ReplyToAuthorID uuid.NullUUID
+	ReplyToType     sql.NullString
+	ReplyToContent  sql.NullString
+}
+
+func (q *Queries) FindMessagesByRoomAfter(ctx context.Context, arg FindMessagesByRoomAfterParams) ([]FindMessagesByRoomAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, findMessagesByRoomAfter,
+		arg.ChatroomID,
+		arg.SentAt,
+		arg.ID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindMessagesByRoomAfterRow
+	for rows.Next() {
+		var i FindMessagesByRoomAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SentAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+			&i.ChatroomID,
+			&i.Type,
+			&i.Content,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.ReplyToAuthorID,
+			&i.ReplyToType,
+			&i.ReplyToContent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findMessagesByRoomBefore = `-- name: FindMessagesByRoomBefore :many
+SELECT m.id, m.sent_at, m.updated_at, m.author_id, m.chatroom_id, m.type, m.content, m.reply_to_id,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+AND (m.sent_at < $2 OR (m.sent_at = $2 AND m.id < $3))
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $4
+`
+
+type FindMessagesByRoomBeforeParams struct {
+	ChatroomID uuid.NullUUID
+	SentAt     time.Time
+	ID         uuid.UUID
+	Limit      int32
+}
+
+type FindMessagesByRoomBeforeRow struct {
+	ID              uuid.UUID
+	SentAt          time.Time
+	UpdatedAt       time.Time
+	AuthorID        uuid.NullUUID
+	ChatroomID      uuid.NullUUID
+	Type            string
+	Content         sql.NullString
+	ReplyToID       uuid.NullUUID
+	ReplyCount      int64
+	ReplyToAuthorID uuid.NullUUID
+	ReplyToType     sql.NullString
+	ReplyToContent  sql.NullString
+}
+
+func (q *Queries) FindMessagesByRoomBefore(ctx context.Context, arg FindMessagesByRoomBeforeParams) ([]FindMessagesByRoomBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, findMessagesByRoomBefore,
+		arg.ChatroomID,
+		arg.SentAt,
+		arg.ID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindMessagesByRoomBeforeRow
+	for rows.Next() {
+		var i FindMessagesByRoomBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SentAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+			&i.ChatroomID,
+			&i.Type,
+			&i.Content,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.ReplyToAuthorID,
+			&i.ReplyToType,
+			&i.ReplyToContent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const findMessagesByRoomById = `-- name: FindMessagesByRoomById :many
-SELECT id, sent_at, updated_at, author_id, chatroom_id, type, content 
+SELECT m.id, m.sent_at, m.updated_at, m.author_id, m.chatroom_id, m.type, m.content, m.reply_to_id,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $2
+`
+
+type FindMessagesByRoomByIdParams struct {
+	ChatroomID uuid.NullUUID
+	Limit      int32
+}
+
+type FindMessagesByRoomByIdRow struct {
+	ID              uuid.UUID
+	SentAt          time.Time
+	UpdatedAt       time.Time
+	AuthorID        uuid.NullUUID
+	ChatroomID      uuid.NullUUID
+	Type            string
+	Content         sql.NullString
+	ReplyToID       uuid.NullUUID
+	ReplyCount      int64
+	ReplyToAuthorID uuid.NullUUID
+	ReplyToType     sql.NullString
+	ReplyToContent  sql.NullString
+}
+
+func (q *Queries) FindMessagesByRoomById(ctx context.Context, arg FindMessagesByRoomByIdParams) ([]FindMessagesByRoomByIdRow, error) {
+	rows, err := q.db.QueryContext(ctx, findMessagesByRoomById, arg.ChatroomID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindMessagesByRoomByIdRow
+	for rows.Next() {
+		var i FindMessagesByRoomByIdRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SentAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+			&i.ChatroomID,
+			&i.Type,
+			&i.Content,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.ReplyToAuthorID,
+			&i.ReplyToType,
+			&i.ReplyToContent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findRepliesByMessageId = `-- name: FindRepliesByMessageId :many
+SELECT id, sent_at, updated_at, author_id, chatroom_id, type, content, reply_to_id
 FROM messages
-WHERE chatroom_id = $1
-ORDER BY sent_at DESC
+WHERE reply_to_id = $1
+ORDER BY sent_at ASC, id ASC
 `
 
-func (q *Queries) FindMessagesByRoomById(ctx context.Context, chatroomID uuid.NullUUID) ([]Message, error) {
-	rows, err := q.db.QueryContext(ctx, findMessagesByRoomById, chatroomID)
+func (q *Queries) FindRepliesByMessageId(ctx context.Context, replyToID uuid.NullUUID) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, findRepliesByMessageId, replyToID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +321,7 @@ func (q *Queries) FindMessagesByRoomById(ctx context.Context, chatroomID uuid.Nu
 			&i.ChatroomID,
 			&i.Type,
 			&i.Content,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -94,11 +336,34 @@ func (q *Queries) FindMessagesByRoomById(ctx context.Context, chatroomID uuid.Nu
 	return items, nil
 }
 
+const tombstoneMessage = `-- name: TombstoneMessage :one
+UPDATE messages
+SET type = 'deleted', content = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, sent_at, updated_at, author_id, chatroom_id, type, content, reply_to_id
+`
+
+func (q *Queries) TombstoneMessage(ctx context.Context, id uuid.UUID) (Message, error) {
+	row := q.db.QueryRowContext(ctx, tombstoneMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SentAt,
+		&i.UpdatedAt,
+		&i.AuthorID,
+		&i.ChatroomID,
+		&i.Type,
+		&i.Content,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
 const updateMessage = `-- name: UpdateMessage :one
 UPDATE messages
 SET content = $1, updated_at = NOW()
 WHERE id = $2
-RETURNING id, sent_at, updated_at, author_id, chatroom_id, type, content
+RETURNING id, sent_at, updated_at, author_id, chatroom_id, type, content, reply_to_id
 `
 
 type UpdateMessageParams struct {
@@ -117,6 +382,7 @@ func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) (M
 		&i.ChatroomID,
 		&i.Type,
 		&i.Content,
+		&i.ReplyToID,
 	)
 	return i, err
 }
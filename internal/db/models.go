@@ -6,11 +6,29 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID              uuid.UUID
+	MessageID       uuid.UUID
+	Name            string
+	MimeType        string
+	Size            int64
+	Checksum        string
+	StorageKey      string
+	CreatedAt       time.Time
+	Width           sql.NullInt32
+	Height          sql.NullInt32
+	ThumbnailStatus string
+	ThumbnailKey    sql.NullString
+	ThumbnailWidth  sql.NullInt32
+	ThumbnailHeight sql.NullInt32
+}
+
 type Chatroom struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -19,9 +37,41 @@ type Chatroom struct {
 	Name      sql.NullString
 }
 
+type ChatroomEvent struct {
+	ChatroomID uuid.UUID
+	Seq        int64
+	Type       string
+	ResourceID uuid.UUID
+	Data       json.RawMessage
+	CreatedAt  time.Time
+}
+
+type ChatroomEventSeq struct {
+	ChatroomID uuid.UUID
+	Seq        int64
+}
+
 type ChatroomsParticipant struct {
-	ChatroomID    uuid.NullUUID
-	ParticipantID uuid.NullUUID
+	ChatroomID        uuid.NullUUID
+	ParticipantID     uuid.NullUUID
+	Role              string
+	LastReadMessageID uuid.NullUUID
+	LastReadAt        sql.NullTime
+	JoinedAt          time.Time
+}
+
+type Contact struct {
+	RequesterID uuid.UUID
+	AddresseeID uuid.UUID
+	Status      string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type DirectChatroom struct {
+	ChatroomID uuid.UUID
+	UserLowID  uuid.UUID
+	UserHighID uuid.UUID
 }
 
 type Message struct {
@@ -32,6 +82,22 @@ type Message struct {
 	ChatroomID uuid.NullUUID
 	Type       string
 	Content    sql.NullString
+	ReplyToID  uuid.NullUUID
+}
+
+type MessageReaction struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	Emoji     string
+	CreatedAt time.Time
+}
+
+type MessageRevision struct {
+	ID        uuid.UUID
+	MessageID uuid.UUID
+	EditorID  uuid.NullUUID
+	Content   sql.NullString
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -50,4 +116,12 @@ type User struct {
 	HashedPassword string
 	Nickname       string
 	Email          string
+	ContactsOnly   bool
+	Discoverable   bool
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
 }
@@ -14,7 +14,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, email, hashed_password, nickname, created_at, updated_at)
 VALUES ($1, $2, $3, $4, NOW(), NOW())
-RETURNING id, created_at, updated_at, hashed_password, nickname, email
+RETURNING id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable
 `
 
 type CreateUserParams struct {
@@ -39,12 +39,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.Nickname,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.Discoverable,
 	)
 	return i, err
 }
 
 const findUserByEmail = `-- name: FindUserByEmail :one
-SELECT id, created_at, updated_at, hashed_password, nickname, email FROM users WHERE email = $1
+SELECT id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable FROM users WHERE email = $1
 `
 
 func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, error) {
@@ -57,12 +59,14 @@ func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, erro
 		&i.HashedPassword,
 		&i.Nickname,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.Discoverable,
 	)
 	return i, err
 }
 
 const findUserById = `-- name: FindUserById :one
-SELECT id, created_at, updated_at, hashed_password, nickname, email FROM users WHERE id = $1
+SELECT id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable FROM users WHERE id = $1
 `
 
 func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -75,6 +79,8 @@ func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 		&i.HashedPassword,
 		&i.Nickname,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.Discoverable,
 	)
 	return i, err
 }
@@ -88,17 +94,132 @@ func (q *Queries) NukeUsers(ctx context.Context) error {
 	return err
 }
 
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable FROM users
+WHERE id <> $1
+AND discoverable
+AND lower(nickname) LIKE $2
+AND NOT EXISTS(
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = $1 AND blocked_id = users.id)
+	OR (blocker_id = users.id AND blocked_id = $1)
+)
+ORDER BY lower(nickname) ASC, id ASC
+LIMIT $3
+`
+
+type SearchUsersParams struct {
+	ID    uuid.UUID
+	Lower string
+	Limit int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.ID, arg.Lower, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.Nickname,
+			&i.Email,
+			&i.ContactsOnly,
+			&i.Discoverable,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsersAfter = `-- name: SearchUsersAfter :many
+SELECT id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable FROM users
+WHERE id <> $1
+AND discoverable
+AND lower(nickname) LIKE $2
+AND (lower(nickname) > $3 OR (lower(nickname) = $3 AND id > $4))
+AND NOT EXISTS(
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = $1 AND blocked_id = users.id)
+	OR (blocker_id = users.id AND blocked_id = $1)
+)
+ORDER BY lower(nickname) ASC, id ASC
+LIMIT $5
+`
+
+type SearchUsersAfterParams struct {
+	ID      uuid.UUID
+	Lower   string
+	Lower_2 string
+	ID_2    uuid.UUID
+	Limit   int32
+}
+
+func (q *Queries) SearchUsersAfter(ctx context.Context, arg SearchUsersAfterParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsersAfter,
+		arg.ID,
+		arg.Lower,
+		arg.Lower_2,
+		arg.ID_2,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HashedPassword,
+			&i.Nickname,
+			&i.Email,
+			&i.ContactsOnly,
+			&i.Discoverable,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2, nickname = $3, updated_at = NOW()
-WHERE id = $4
-RETURNING id, created_at, updated_at, hashed_password, nickname, email
+SET email = $1, hashed_password = $2, nickname = $3, contacts_only = $4, discoverable = $5, updated_at = NOW()
+WHERE id = $6
+RETURNING id, created_at, updated_at, hashed_password, nickname, email, contacts_only, discoverable
 `
 
 type UpdateUserParams struct {
 	Email          string
 	HashedPassword string
 	Nickname       string
+	ContactsOnly   bool
+	Discoverable   bool
 	ID             uuid.UUID
 }
 
@@ -107,6 +228,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		arg.Email,
 		arg.HashedPassword,
 		arg.Nickname,
+		arg.ContactsOnly,
+		arg.Discoverable,
 		arg.ID,
 	)
 	var i User
@@ -117,6 +240,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.HashedPassword,
 		&i.Nickname,
 		&i.Email,
+		&i.ContactsOnly,
+		&i.Discoverable,
 	)
 	return i, err
 }
@@ -0,0 +1,203 @@
+// Package dbtest fakes the database in tests. Its DB is a database/sql driver
+// answering the sqlc queries by name, so code using db.Queries runs without
+// postgres.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/fernandofreamunde/ika/internal/database"
+	"github.com/fernandofreamunde/ika/internal/db"
+)
+
+// ErrInjected is what queries made to fail by FailOn return.
+var ErrInjected = errors.New("injected failure")
+
+var queryName = regexp.MustCompile(`-- name: (\w+)`)
+
+// Result builds the rows a query returns from its arguments. Queries without
+// a result return no rows.
+type Result func(args []driver.Value) (columns []string, rows [][]driver.Value)
+
+// DB is a database.Service whose queries return the Results of the same
+// name. It records queries and transactions, and fails the n-th run of a
+// query on demand.
+type DB struct {
+	// Results may be changed until the first query runs.
+	Results map[string]Result
+
+	mu        sync.Mutex
+	failQuery string
+	failAt    int
+	calls     map[string]int
+	commits   int
+	rollbacks int
+
+	conn    *sql.DB
+	queries *db.Queries
+}
+
+func New(results map[string]Result) *DB {
+	f := &DB{Results: results, calls: map[string]int{}}
+	f.conn = sql.OpenDB(connector{f})
+	f.queries = db.New(f.conn)
+	return f
+}
+
+func (f *DB) Health() map[string]string             { return map[string]string{"status": "up"} }
+func (f *DB) Close() error                          { return f.conn.Close() }
+func (f *DB) Queries() *db.Queries                  { return f.queries }
+func (f *DB) Migrate(context.Context, string) error { return nil }
+
+func (f *DB) WithTx(ctx context.Context, fn func(dbq func() *db.Queries) error) error {
+	return database.RunInTx(ctx, f.conn, f.queries, fn)
+}
+
+// FailOn makes the n-th run of the named query fail with ErrInjected.
+func (f *DB) FailOn(name string, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failQuery, f.failAt = name, n
+}
+
+// Calls returns how many times the named query ran.
+func (f *DB) Calls(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[name]
+}
+
+// Commits returns how many transactions were committed.
+func (f *DB) Commits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commits
+}
+
+// Rollbacks returns how many transactions were rolled back.
+func (f *DB) Rollbacks() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rollbacks
+}
+
+func (f *DB) call(query string, named []driver.NamedValue) (Result, []driver.Value, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := ""
+	if m := queryName.FindStringSubmatch(query); m != nil {
+		name = m[1]
+	}
+	f.calls[name]++
+	if name == f.failQuery && f.calls[name] == f.failAt {
+		return nil, nil, ErrInjected
+	}
+
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		args[i] = nv.Value
+	}
+	return f.Results[name], args, nil
+}
+
+type connector struct{ f *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn{c.f}, nil }
+func (c connector) Driver() driver.Driver                        { return nil }
+
+type conn struct{ f *DB }
+
+func (c conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c conn) Close() error              { return nil }
+func (c conn) Begin() (driver.Tx, error) { return tx{c.f}, nil }
+
+func (c conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, values, err := c.f.call(query, args)
+	if err != nil {
+		return nil, err
+	}
+	// Statements have no rows, their result only sees the arguments.
+	if result != nil {
+		result(values)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, values, err := c.f.call(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return &resultRows{}, nil
+	}
+	columns, rows := result(values)
+	return &resultRows{columns: columns, rows: rows}, nil
+}
+
+type tx struct{ f *DB }
+
+func (t tx) Commit() error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.commits++
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.rollbacks++
+	return nil
+}
+
+type resultRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *resultRows) Columns() []string { return r.columns }
+func (r *resultRows) Close() error      { return nil }
+
+func (r *resultRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// Columns returns n column names, sqlc scans by position so any will do.
+func Columns(n int) []string {
+	cols := make([]string, n)
+	for i := range cols {
+		cols[i] = fmt.Sprintf("c%d", i)
+	}
+	return cols
+}
+
+// Rows returns the given rows with as many columns as the first has.
+func Rows(values ...[]driver.Value) ([]string, [][]driver.Value) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return Columns(len(values[0])), values
+}
+
+// One returns a single row.
+func One(values ...driver.Value) Result {
+	return func([]driver.Value) ([]string, [][]driver.Value) {
+		return Rows(values)
+	}
+}
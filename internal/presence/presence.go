@@ -0,0 +1,280 @@
+package presence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
+)
+
+const (
+	// Users who did nothing for this long are away, even when connected.
+	DefaultAwayAfter = 5 * time.Minute
+
+	// Users without a live connection count as online for this long after
+	// their last activity, so clients only using the REST API show up too.
+	DefaultOnlineTTL = time.Minute
+
+	// Typing stops on its own if the client does not repeat it in time.
+	DefaultTypingTTL = 6 * time.Second
+
+	// Users who are offline for this long are forgotten, so the tracker only
+	// holds recently active users.
+	DefaultForgetAfter = 24 * time.Hour
+
+	// How often, at most, adding a user sweeps out the forgotten ones.
+	pruneInterval = time.Minute
+)
+
+// Presence is what other users see of someone's presence. LastSeen is only
+// known for users active since the server started, within ForgetAfter.
+type Presence struct {
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// TypingFunc is called whenever a user starts or stops typing in a chatroom.
+type TypingFunc func(userID, roomID uuid.UUID, typing bool)
+
+type entry struct {
+	connections int
+	lastActive  time.Time
+	typing      map[uuid.UUID]*time.Timer
+}
+
+// Tracker keeps, in memory, who is online and who is typing where. A nil
+// *Tracker knows nothing and reports everyone offline.
+type Tracker struct {
+	AwayAfter   time.Duration
+	OnlineTTL   time.Duration
+	TypingTTL   time.Duration
+	ForgetAfter time.Duration
+
+	mu       sync.Mutex
+	users    map[uuid.UUID]*entry
+	pruned   time.Time
+	onTyping TypingFunc
+	// notifyMu keeps notifications in order without holding mu while the
+	// callback runs.
+	notifyMu sync.Mutex
+	now      func() time.Time
+}
+
+func NewTracker(onTyping TypingFunc) *Tracker {
+	return &Tracker{
+		AwayAfter:   DefaultAwayAfter,
+		OnlineTTL:   DefaultOnlineTTL,
+		TypingTTL:   DefaultTypingTTL,
+		ForgetAfter: DefaultForgetAfter,
+		users:       make(map[uuid.UUID]*entry),
+		onTyping:    onTyping,
+		now:         time.Now,
+	}
+}
+
+func (t *Tracker) entry(userID uuid.UUID) *entry {
+	e, ok := t.users[userID]
+	if !ok {
+		// Only new users grow the map, so this is where old ones go.
+		if now := t.now(); now.Sub(t.pruned) >= pruneInterval {
+			t.prune(now)
+			t.pruned = now
+		}
+
+		e = &entry{typing: make(map[uuid.UUID]*time.Timer)}
+		t.users[userID] = e
+	}
+	return e
+}
+
+// prune forgets the users without connections who are not typing and were
+// last active ForgetAfter ago.
+func (t *Tracker) prune(now time.Time) {
+	for userID, e := range t.users {
+		if e.connections == 0 && len(e.typing) == 0 && now.Sub(e.lastActive) >= t.ForgetAfter {
+			delete(t.users, userID)
+		}
+	}
+}
+
+// Connect records a new live connection of the user, Disconnect must be
+// called once it goes away.
+func (t *Tracker) Connect(userID uuid.UUID) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.entry(userID)
+	e.connections++
+	e.lastActive = t.now()
+}
+
+func (t *Tracker) Disconnect(userID uuid.UUID) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.entry(userID)
+	if e.connections > 0 {
+		e.connections--
+	}
+	e.lastActive = t.now()
+}
+
+// Touch records activity of the user.
+func (t *Tracker) Touch(userID uuid.UUID) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entry(userID).lastActive = t.now()
+}
+
+// Lookup returns the presence of the user.
+func (t *Tracker) Lookup(userID uuid.UUID) Presence {
+	if t == nil {
+		return Presence{Status: StatusOffline}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.users[userID]
+	if !ok {
+		return Presence{Status: StatusOffline}
+	}
+
+	lastSeen := e.lastActive
+	idle := t.now().Sub(lastSeen)
+	p := Presence{Status: StatusOffline, LastSeen: &lastSeen}
+
+	switch {
+	case e.connections > 0 && idle < t.AwayAfter:
+		p.Status = StatusOnline
+	case e.connections > 0:
+		p.Status = StatusAway
+	case idle < t.OnlineTTL:
+		p.Status = StatusOnline
+	case idle < t.AwayAfter:
+		p.Status = StatusAway
+	}
+
+	return p
+}
+
+// SetTyping marks the user as typing in the chatroom until StopTyping is
+// called or TypingTTL passes without another call.
+func (t *Tracker) SetTyping(userID, roomID uuid.UUID) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+
+	e := t.entry(userID)
+	e.lastActive = t.now()
+
+	if timer, ok := e.typing[roomID]; ok {
+		// Only restart the timer if it has not fired yet, otherwise the
+		// expiry is already on its way and typing starts over below.
+		if timer.Stop() {
+			timer.Reset(t.TypingTTL)
+			t.mu.Unlock()
+			return
+		}
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(t.TypingTTL, func() {
+		t.expireTyping(userID, roomID, timer)
+	})
+	e.typing[roomID] = timer
+
+	t.unlockAndNotify(userID, roomID, true)
+}
+
+// StopTyping clears the user's typing state in the chatroom, e.g. once the
+// message got sent.
+func (t *Tracker) StopTyping(userID, roomID uuid.UUID) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+
+	e, ok := t.users[userID]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+
+	timer, ok := e.typing[roomID]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+
+	timer.Stop()
+	delete(e.typing, roomID)
+	t.unlockAndNotify(userID, roomID, false)
+}
+
+func (t *Tracker) expireTyping(userID, roomID uuid.UUID, timer *time.Timer) {
+	t.mu.Lock()
+
+	// Typing may have been stopped, or started over, meanwhile.
+	e, ok := t.users[userID]
+	if !ok || e.typing[roomID] != timer {
+		t.mu.Unlock()
+		return
+	}
+
+	delete(e.typing, roomID)
+	t.unlockAndNotify(userID, roomID, false)
+}
+
+// Typing returns who is typing in the chatroom.
+func (t *Tracker) Typing(roomID uuid.UUID) []uuid.UUID {
+	if t == nil {
+		return []uuid.UUID{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	typing := []uuid.UUID{}
+	for userID, e := range t.users {
+		if _, ok := e.typing[roomID]; ok {
+			typing = append(typing, userID)
+		}
+	}
+	return typing
+}
+
+// unlockAndNotify releases the lock and then calls the callback, so a slow
+// callback does not hold up lookups. notifyMu is taken before the lock is
+// released, so notifications still go out in the order of the changes.
+func (t *Tracker) unlockAndNotify(userID, roomID uuid.UUID, typing bool) {
+	t.notifyMu.Lock()
+	defer t.notifyMu.Unlock()
+	t.mu.Unlock()
+
+	if t.onTyping != nil {
+		t.onTyping(userID, roomID, typing)
+	}
+}
@@ -0,0 +1,204 @@
+package presence
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLookup(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(nil)
+	tracker.now = func() time.Time { return now }
+
+	userID := uuid.New()
+
+	if got := tracker.Lookup(userID).Status; got != StatusOffline {
+		t.Errorf("unknown user is %s, want offline", got)
+	}
+
+	tracker.Connect(userID)
+	if got := tracker.Lookup(userID).Status; got != StatusOnline {
+		t.Errorf("connected user is %s, want online", got)
+	}
+
+	now = now.Add(DefaultAwayAfter)
+	if got := tracker.Lookup(userID).Status; got != StatusAway {
+		t.Errorf("idle connected user is %s, want away", got)
+	}
+
+	tracker.Touch(userID)
+	tracker.Disconnect(userID)
+	if got := tracker.Lookup(userID).Status; got != StatusOnline {
+		t.Errorf("just disconnected user is %s, want online", got)
+	}
+
+	now = now.Add(DefaultOnlineTTL)
+	if got := tracker.Lookup(userID).Status; got != StatusAway {
+		t.Errorf("disconnected user is %s, want away", got)
+	}
+
+	now = now.Add(DefaultAwayAfter)
+	p := tracker.Lookup(userID)
+	if p.Status != StatusOffline {
+		t.Errorf("long gone user is %s, want offline", p.Status)
+	}
+	if p.LastSeen == nil {
+		t.Error("expected last seen to be known")
+	}
+
+	var nilTracker *Tracker
+	if got := nilTracker.Lookup(userID).Status; got != StatusOffline {
+		t.Errorf("nil tracker reports %s, want offline", got)
+	}
+}
+
+type typingEvent struct {
+	userID, roomID uuid.UUID
+	typing         bool
+}
+
+func TestTypingExpires(t *testing.T) {
+	var mu sync.Mutex
+	var events []typingEvent
+	tracker := NewTracker(func(userID, roomID uuid.UUID, typing bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, typingEvent{userID, roomID, typing})
+	})
+	tracker.TypingTTL = 20 * time.Millisecond
+
+	userID, roomID := uuid.New(), uuid.New()
+
+	tracker.SetTyping(userID, roomID)
+	tracker.SetTyping(userID, roomID)
+
+	if typing := tracker.Typing(roomID); len(typing) != 1 || typing[0] != userID {
+		t.Fatalf("Typing() = %v, want [%s]", typing, userID)
+	}
+
+	// The stop notification goes out once typing is cleared, so wait for it.
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("typing did not expire")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if typing := tracker.Typing(roomID); len(typing) != 0 {
+		t.Errorf("Typing() = %v, want nobody", typing)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []typingEvent{{userID, roomID, true}, {userID, roomID, false}}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestStopTyping(t *testing.T) {
+	stopped := 0
+	tracker := NewTracker(func(userID, roomID uuid.UUID, typing bool) {
+		if !typing {
+			stopped++
+		}
+	})
+
+	userID, roomID := uuid.New(), uuid.New()
+
+	tracker.StopTyping(userID, roomID)
+	tracker.SetTyping(userID, roomID)
+	tracker.StopTyping(userID, roomID)
+	tracker.StopTyping(userID, roomID)
+
+	if len(tracker.Typing(roomID)) != 0 {
+		t.Error("user is still typing")
+	}
+	if stopped != 1 {
+		t.Errorf("got %d stop notifications, want 1", stopped)
+	}
+}
+
+func TestForgetsOfflineUsers(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(nil)
+	tracker.now = func() time.Time { return now }
+
+	gone, connected, recent := uuid.New(), uuid.New(), uuid.New()
+	tracker.Touch(gone)
+	tracker.Connect(connected)
+
+	now = now.Add(DefaultForgetAfter)
+	tracker.Touch(recent)
+
+	if _, ok := tracker.users[gone]; ok {
+		t.Error("expected the long gone user to be forgotten")
+	}
+	for _, userID := range []uuid.UUID{connected, recent} {
+		if _, ok := tracker.users[userID]; !ok {
+			t.Errorf("expected user %s to be kept", userID)
+		}
+	}
+	if p := tracker.Lookup(gone); p.Status != StatusOffline || p.LastSeen != nil {
+		t.Errorf("forgotten user is %+v, want offline without last seen", p)
+	}
+}
+
+func TestSlowCallbackDoesNotBlockLookups(t *testing.T) {
+	release := make(chan struct{})
+	tracker := NewTracker(func(userID, roomID uuid.UUID, typing bool) {
+		<-release
+	})
+
+	userID, roomID := uuid.New(), uuid.New()
+
+	done := make(chan struct{})
+	go func() {
+		tracker.SetTyping(userID, roomID)
+		close(done)
+	}()
+
+	// SetTyping is stuck in the callback until released, the tracker is not.
+	deadline := time.Now().Add(time.Second)
+	for len(tracker.Typing(roomID)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("typing was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tracker.Touch(userID)
+	if got := tracker.Lookup(userID).Status; got != StatusOnline {
+		t.Errorf("typing user is %s, want online", got)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	userID, roomID := uuid.New(), uuid.New()
+
+	tracker.Connect(userID)
+	tracker.Touch(userID)
+	tracker.SetTyping(userID, roomID)
+	tracker.StopTyping(userID, roomID)
+	tracker.Disconnect(userID)
+
+	if got := tracker.Lookup(userID).Status; got != StatusOffline {
+		t.Errorf("user is %s, want offline", got)
+	}
+	if got := tracker.Typing(roomID); len(got) != 0 {
+		t.Errorf("typing = %v, want nobody", got)
+	}
+}
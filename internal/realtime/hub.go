@@ -0,0 +1,252 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// sendBufferSize is how many encoded events may queue up for a single client
+// before it is considered too slow and gets disconnected.
+const sendBufferSize = 64
+
+const (
+	EventMessageCreated  = "message.created"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventMessageRead     = "message.read"
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+	EventChatroomUpdated = "chatroom.updated"
+	EventChatroomDeleted = "chatroom.deleted"
+	EventTypingStarted   = "typing.started"
+	EventTypingStopped   = "typing.stopped"
+
+	// EventChatroomResync tells an event stream client it missed more
+	// events than can be replayed, and should reload the chatroom.
+	EventChatroomResync = "chatroom.resync"
+)
+
+// Event is a single notification pushed to every client subscribed to a chatroom.
+// ID is the ID of the resource the event is about, e.g. the message. Seq
+// numbers the events of the chatroom that can be replayed; others, like
+// typing, have none.
+type Event struct {
+	Type       string    `json:"type"`
+	Seq        int64     `json:"seq,omitempty"`
+	ID         uuid.UUID `json:"id"`
+	ChatroomID uuid.UUID `json:"chatroom_id"`
+	Data       any       `json:"data"`
+}
+
+// frame is an event together with its JSON encoding, so it is only encoded
+// once no matter how many clients receive it.
+type frame struct {
+	event   Event
+	payload []byte
+}
+
+func newFrame(e Event) (frame, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return frame{}, err
+	}
+	return frame{event: e, payload: payload}, nil
+}
+
+// Client is one live connection of a user. A user may hold several clients at
+// once (e.g. phone and browser), each with its own subscriptions.
+type Client struct {
+	UserID uuid.UUID
+
+	// pinned clients listen to a fixed chatroom and are not subscribed to
+	// the rooms the user joins later on.
+	pinned bool
+
+	send      chan frame
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Done is closed once the client has been disconnected from the hub.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Hub keeps track of the connected clients and the chatrooms they listen to.
+type Hub struct {
+	mu      sync.RWMutex
+	rooms   map[uuid.UUID]map[*Client]struct{}
+	clients map[*Client]map[uuid.UUID]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		rooms:   make(map[uuid.UUID]map[*Client]struct{}),
+		clients: make(map[*Client]map[uuid.UUID]struct{}),
+	}
+}
+
+// Register adds a new client for the user, subscribed to the given chatrooms
+// and to any chatroom the user joins while connected.
+func (h *Hub) Register(userID uuid.UUID, roomIDs []uuid.UUID) (*Client, error) {
+	return h.register(userID, roomIDs, false)
+}
+
+// RegisterRoom adds a new client for the user that only listens to one chatroom.
+func (h *Hub) RegisterRoom(userID, roomID uuid.UUID) (*Client, error) {
+	return h.register(userID, []uuid.UUID{roomID}, true)
+}
+
+func (h *Hub) register(userID uuid.UUID, roomIDs []uuid.UUID, pinned bool) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, fmt.Errorf("hub is closed")
+	}
+
+	c := &Client{
+		UserID: userID,
+		pinned: pinned,
+		send:   make(chan frame, sendBufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.clients[c] = make(map[uuid.UUID]struct{})
+	for _, roomID := range roomIDs {
+		h.subscribe(c, roomID)
+	}
+
+	return c, nil
+}
+
+// Unregister drops the client and all of its subscriptions.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c)
+}
+
+// Join subscribes every live client of the user to the chatroom.
+func (h *Hub) Join(userID, roomID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		if c.UserID == userID && !c.pinned {
+			h.subscribe(c, roomID)
+		}
+	}
+}
+
+// Leave unsubscribes every live client of the user from the chatroom.
+// Clients pinned to that chatroom have nothing left to listen to and are
+// disconnected.
+func (h *Hub) Leave(userID, roomID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.rooms[roomID] {
+		if c.UserID != userID {
+			continue
+		}
+		if c.pinned {
+			h.remove(c)
+		} else {
+			h.unsubscribe(c, roomID)
+		}
+	}
+}
+
+// Subscribed tells if the client listens to the chatroom.
+func (h *Hub) Subscribed(c *Client, roomID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, ok := h.clients[c][roomID]
+	return ok
+}
+
+// Publish pushes the event to every client subscribed to its chatroom.
+// Clients that can not keep up are disconnected instead of blocking the caller.
+func (h *Hub) Publish(e Event) {
+	f, err := newFrame(e)
+	if err != nil {
+		log.Printf("Err encoding realtime event: %v", err)
+		return
+	}
+
+	var slow []*Client
+
+	h.mu.RLock()
+	for c := range h.rooms[e.ChatroomID] {
+		select {
+		case c.send <- f:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range slow {
+		log.Printf("Dropping slow realtime client of user %s", c.UserID)
+		h.remove(c)
+	}
+}
+
+// Close disconnects every client and refuses new registrations.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		h.remove(c)
+	}
+}
+
+func (h *Hub) subscribe(c *Client, roomID uuid.UUID) {
+	if _, ok := h.rooms[roomID]; !ok {
+		h.rooms[roomID] = make(map[*Client]struct{})
+	}
+	h.rooms[roomID][c] = struct{}{}
+	h.clients[c][roomID] = struct{}{}
+}
+
+func (h *Hub) unsubscribe(c *Client, roomID uuid.UUID) {
+	delete(h.rooms[roomID], c)
+	if len(h.rooms[roomID]) == 0 {
+		delete(h.rooms, roomID)
+	}
+	delete(h.clients[c], roomID)
+}
+
+func (h *Hub) remove(c *Client) {
+	rooms, ok := h.clients[c]
+	if !ok {
+		return
+	}
+	for roomID := range rooms {
+		h.unsubscribe(c, roomID)
+	}
+	delete(h.clients, c)
+	c.close()
+}
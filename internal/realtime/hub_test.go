@@ -0,0 +1,107 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishReachesOnlyRoomSubscribers(t *testing.T) {
+	h := NewHub()
+	room := uuid.New()
+
+	in, err := h.Register(uuid.New(), []uuid.UUID{room})
+	if err != nil {
+		t.Fatalf("Failed to register client: %v", err)
+	}
+	out, err := h.Register(uuid.New(), []uuid.UUID{uuid.New()})
+	if err != nil {
+		t.Fatalf("Failed to register client: %v", err)
+	}
+
+	h.Publish(Event{Type: EventMessageCreated, ChatroomID: room, Data: "hi"})
+
+	select {
+	case f := <-in.send:
+		var e Event
+		if err := json.Unmarshal(f.payload, &e); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if e.ChatroomID != room || e.Type != EventMessageCreated {
+			t.Fatalf("Received unexpected event: %s", f.payload)
+		}
+	default:
+		t.Fatal("Subscribed client did not receive the event")
+	}
+
+	select {
+	case f := <-out.send:
+		t.Fatalf("Client of another room received the event: %s", f.payload)
+	default:
+	}
+}
+
+func TestJoinAndLeave(t *testing.T) {
+	h := NewHub()
+	room := uuid.New()
+	userID := uuid.New()
+
+	c, _ := h.Register(userID, nil)
+
+	h.Join(userID, room)
+	h.Publish(Event{Type: EventMessageCreated, ChatroomID: room})
+	if len(c.send) != 1 {
+		t.Fatal("Client did not receive event after joining the room")
+	}
+	if !h.Subscribed(c, room) {
+		t.Fatal("Client is not subscribed after joining the room")
+	}
+	<-c.send
+
+	h.Leave(userID, room)
+	h.Publish(Event{Type: EventMessageCreated, ChatroomID: room})
+	if len(c.send) != 0 {
+		t.Fatal("Client received event after leaving the room")
+	}
+	if h.Subscribed(c, room) {
+		t.Fatal("Client is still subscribed after leaving the room")
+	}
+}
+
+func TestCloseDisconnectsClients(t *testing.T) {
+	h := NewHub()
+
+	c, _ := h.Register(uuid.New(), []uuid.UUID{uuid.New()})
+	h.Close()
+
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("Client was not disconnected when the hub closed")
+	}
+
+	if _, err := h.Register(uuid.New(), nil); err == nil {
+		t.Fatal("Hub accepted a client after being closed")
+	}
+}
+
+func TestPinnedClientIgnoresJoinAndDisconnectsOnLeave(t *testing.T) {
+	h := NewHub()
+	room := uuid.New()
+	userID := uuid.New()
+
+	c, _ := h.RegisterRoom(userID, room)
+
+	h.Join(userID, uuid.New())
+	if len(h.clients[c]) != 1 {
+		t.Fatal("Pinned client was subscribed to a room it did not ask for")
+	}
+
+	h.Leave(userID, room)
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("Pinned client was not disconnected after leaving its room")
+	}
+}
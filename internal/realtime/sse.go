@@ -0,0 +1,88 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Send a comment line with this period so proxies do not close idle streams.
+const keepAlivePeriod = 30 * time.Second
+
+// ServeSSE writes the backlog followed by the client's live events to w as a
+// text/event-stream, until the request is cancelled or the client is
+// disconnected. Events with a Seq carry it as their `id:` so browsers resume
+// from it through the Last-Event-ID header. Live events already sent as part
+// of the backlog are skipped. It blocks, and unregisters the client before
+// returning.
+func ServeSSE(h *Hub, c *Client, w http.ResponseWriter, r *http.Request, backlog []Event) error {
+	defer h.Unregister(c)
+
+	rc := http.NewResponseController(w)
+	// The stream is long lived, so the server wide write timeout does not apply.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Live events are numbered before they are published, so any of them up
+	// to the last replayed one was already sent.
+	var replayed int64
+	for _, e := range backlog {
+		f, err := newFrame(e)
+		if err != nil {
+			return err
+		}
+		if err := writeSSE(w, f); err != nil {
+			return err
+		}
+		replayed = max(replayed, e.Seq)
+	}
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(keepAlivePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case f := <-c.send:
+			if f.event.Seq > 0 && f.event.Seq <= replayed {
+				continue
+			}
+			if err := writeSSE(w, f); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case <-c.done:
+			return nil
+		case <-r.Context().Done():
+			return nil
+		}
+
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, f frame) error {
+	// Events that are not replayed leave the last event ID alone.
+	if f.event.Seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", f.event.Seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", f.event.Type, f.payload)
+	return err
+}
@@ -0,0 +1,54 @@
+package realtime
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestServeSSEReplaysBacklogWithoutDuplicates(t *testing.T) {
+	h := NewHub()
+	room := uuid.New()
+
+	c, _ := h.RegisterRoom(uuid.New(), room)
+
+	// Published before the stream starts, as if they raced with the backlog query.
+	h.Publish(Event{Type: EventMessageCreated, Seq: 1, ID: uuid.New(), ChatroomID: room})
+	h.Publish(Event{Type: EventMessageUpdated, Seq: 2, ID: uuid.New(), ChatroomID: room})
+	h.Publish(Event{Type: EventTypingStarted, ID: uuid.New(), ChatroomID: room})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/chatrooms/"+room.String()+"/events", nil)
+	backlog := []Event{{Type: EventMessageCreated, Seq: 1, ID: uuid.New(), ChatroomID: room}}
+
+	done := make(chan error)
+	go func() {
+		done <- ServeSSE(h, c, w, r, backlog)
+	}()
+
+	for len(c.send) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	h.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("ServeSSE returned an error: %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream content type, got %s", ct)
+	}
+
+	body := w.Body.String()
+	if n := strings.Count(body, "id: 1\n"); n != 1 {
+		t.Fatalf("expected replayed event to be sent once, was sent %d times:\n%s", n, body)
+	}
+	if !strings.Contains(body, "id: 2\nevent: "+EventMessageUpdated) {
+		t.Fatalf("expected live event in the stream:\n%s", body)
+	}
+	if !strings.Contains(body, "\n\nevent: "+EventTypingStarted) {
+		t.Fatalf("expected typing event without an id in the stream:\n%s", body)
+	}
+}
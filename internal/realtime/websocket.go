@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a frame to the peer.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong from the peer.
+	pongWait = 60 * time.Second
+
+	// Send pings to the peer with this period, must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+)
+
+const (
+	ClientTyping        = "typing"
+	ClientTypingStopped = "typing.stopped"
+)
+
+// ClientMessage is what clients may send over the socket, e.g. that the user
+// is typing in a chatroom.
+type ClientMessage struct {
+	Type       string    `json:"type"`
+	ChatroomID uuid.UUID `json:"chatroom_id"`
+}
+
+// ServeWebsocket pumps the client's events into the connection until either
+// side goes away, passing the messages the client sends to handle. It blocks,
+// and unregisters the client before returning.
+func ServeWebsocket(h *Hub, c *Client, conn *websocket.Conn, handle func(*Client, ClientMessage)) {
+	defer h.Unregister(c)
+
+	go readPump(c, conn, handle)
+	writePump(c, conn)
+}
+
+// readPump processes control frames (pong, close) and the client's messages.
+// Messages that can not be decoded are ignored.
+func readPump(c *Client, conn *websocket.Conn, handle func(*Client, ClientMessage)) {
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Websocket read error: %v", err)
+			}
+			c.close()
+			return
+		}
+
+		var msg ClientMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			continue
+		}
+		if handle != nil {
+			handle(c, msg)
+		}
+	}
+}
+
+func writePump(c *Client, conn *websocket.Conn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case f := <-c.send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, f.payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/google/uuid"
+)
+
+// Requests of different users handled at the same time must each see their
+// own user. Run with -race to also catch shared state.
+func TestAuthMiddlewareConcurrentUsers(t *testing.T) {
+	s := &Server{tokens: auth.NewTokenIssuer("topSecret"), presence: presence.NewTracker(nil)}
+
+	whoami := s.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Give the other requests a chance to run in between.
+		time.Sleep(time.Millisecond)
+		io.WriteString(w, auth.MustUserID(r.Context()).String())
+	}))
+	server := httptest.NewServer(whoami)
+	defer server.Close()
+
+	users := []uuid.UUID{uuid.New(), uuid.New()}
+	tokens := map[uuid.UUID]string{}
+	for _, u := range users {
+		token, err := auth.MakeJWT(u, "topSecret", time.Minute)
+		if err != nil {
+			t.Fatalf("Failed to make JWT: %v", err)
+		}
+		tokens[u] = token
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, u := range users {
+			wg.Add(1)
+			go func(u uuid.UUID) {
+				defer wg.Done()
+
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				req.Header.Set("Authorization", "Bearer "+tokens[u])
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Errorf("error making request to server. Err: %v", err)
+					return
+				}
+				defer resp.Body.Close()
+
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != u.String() {
+					t.Errorf("request of %v was handled as %q", u, body)
+				}
+			}(u)
+		}
+	}
+	wg.Wait()
+}
+
+func TestAuthMiddlewareRejectsMissingToken(t *testing.T) {
+	s := &Server{tokens: auth.NewTokenIssuer("topSecret"), presence: presence.NewTracker(nil)}
+
+	handler := s.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("handler called without a token")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401; got %v", rec.Code)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/pkg/client"
+)
+
+// The client is tested here, against the routes and the fake database the
+// contract test uses.
+
+func newClientServer(t *testing.T) (*Server, fixture, *client.Client) {
+	t.Helper()
+
+	s, fx := newFakeServer(t)
+	ts := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(ts.Close)
+
+	return s, fx, client.New(ts.URL, ts.Client())
+}
+
+func TestClient(t *testing.T) {
+	_, fx, c := newClientServer(t)
+	ctx := context.Background()
+
+	u, err := c.Register(ctx, client.RegisterParams{Email: "tako@example.com", Nickname: "tako", Password: "ink"})
+	if err != nil || u.Nickname != "tako" {
+		t.Fatalf("Register = %+v, %v", u, err)
+	}
+
+	login, err := c.Login(ctx, fx.email, fx.password)
+	if err != nil || login.User.ID != fx.me {
+		t.Fatalf("Login = %+v, %v", login, err)
+	}
+	if token, refreshToken := c.Tokens(); token != login.Token || refreshToken != login.RefreshToken {
+		t.Errorf("Login did not keep the tokens")
+	}
+
+	if me, err := c.Me(ctx); err != nil || me.ID != fx.me || me.Email != fx.email {
+		t.Errorf("Me = %+v, %v", me, err)
+	}
+
+	rooms, err := c.Chatrooms(ctx)
+	if err != nil || len(rooms) != 1 || rooms[0].ID != fx.room || *rooms[0].Name != "crew" {
+		t.Fatalf("Chatrooms = %+v, %v", rooms, err)
+	}
+
+	msg, err := c.SendMessage(ctx, fx.room, client.SendMessageParams{Content: "hello", ReplyToID: &fx.message})
+	if err != nil || msg.ChatroomID != fx.room || msg.Content != "hello" || *msg.ReplyToID != fx.message {
+		t.Fatalf("SendMessage = %+v, %v", msg, err)
+	}
+
+	page, err := c.ListMessages(ctx, fx.room, client.ListMessagesParams{Limit: 2})
+	if err != nil || len(page.Messages) != 1 || page.Messages[0].Attachment == nil {
+		t.Fatalf("ListMessages = %+v, %v", page, err)
+	}
+
+	if err := c.DeleteMessage(ctx, fx.room, fx.message); err != nil {
+		t.Errorf("DeleteMessage = %v", err)
+	}
+
+	_, err = c.CreateGroupChatroom(ctx, " ", nil)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 422 || apiErr.Code != string(CodeValidation) || apiErr.RequestID == "" {
+		t.Errorf("expected a validation error; got %v", err)
+	}
+
+	if err := c.Revoke(ctx); err != nil {
+		t.Errorf("Revoke = %v", err)
+	}
+	if _, err := c.Refresh(ctx); !errors.Is(err, client.ErrNoRefreshToken) {
+		t.Errorf("expected the refresh token to be gone; got %v", err)
+	}
+}
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	_, fx, c := newClientServer(t)
+	ctx := context.Background()
+
+	expired, err := auth.MakeJWT(fx.me, "topSecret", -time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	c.SetTokens(expired, fx.refreshToken)
+
+	if _, err := c.Chatrooms(ctx); err != nil {
+		t.Fatalf("expected the request to go through after a refresh; got %v", err)
+	}
+	if token, _ := c.Tokens(); token == expired {
+		t.Errorf("the access token was not replaced")
+	}
+
+	// Without a valid refresh token the original error comes back.
+	c.SetTokens(expired, "revoked")
+	_, err = c.Chatrooms(ctx)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != string(CodeUnauthorized) {
+		t.Errorf("expected unauthorized; got %v", err)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	_, fx, c := newClientServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expired, err := auth.MakeJWT(fx.me, "topSecret", -time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	c.SetTokens(expired, fx.refreshToken)
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe = %v", err)
+	}
+
+	next := func(eventType string) client.Event {
+		t.Helper()
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					t.Fatalf("subscription ended waiting for %s: %v", eventType, sub.Err())
+				}
+				if e.Type == eventType {
+					return e
+				}
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %s", eventType)
+			}
+		}
+	}
+
+	// The typing event comes back once the server listens to the socket, so
+	// nothing published afterwards is missed.
+	if err := sub.SetTyping(fx.room, true); err != nil {
+		t.Fatalf("SetTyping = %v", err)
+	}
+	if e := next(client.EventTypingStarted); e.ChatroomID != fx.room || e.ID != fx.me {
+		t.Errorf("unexpected typing event: %+v", e)
+	}
+
+	sent, err := c.SendMessage(ctx, fx.room, client.SendMessageParams{Content: "hello"})
+	if err != nil {
+		t.Fatalf("SendMessage = %v", err)
+	}
+
+	var msg client.Message
+	if err := next(client.EventMessageCreated).Decode(&msg); err != nil || msg.ID != sent.ID {
+		t.Errorf("expected the sent message; got %+v, %v", msg, err)
+	}
+
+	sub.Close()
+	for range sub.Events() {
+	}
+	if sub.Err() != nil {
+		t.Errorf("expected a clean close; got %v", sub.Err())
+	}
+}
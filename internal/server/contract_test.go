@@ -0,0 +1,492 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/fernandofreamunde/ika/internal/realtime"
+	"github.com/fernandofreamunde/ika/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// openAPI is the part of the document the contract test checks responses
+// against.
+type openAPI struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Responses map[string]response `json:"responses"`
+		Schemas   map[string]*schema  `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	Responses map[string]response `json:"responses"`
+}
+
+type response struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	AllOf                []*schema          `json:"allOf"`
+}
+
+func loadSpec(t *testing.T) openAPI {
+	t.Helper()
+
+	var spec openAPI
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid: %v", err)
+	}
+	return spec
+}
+
+// resolve follows $ref and merges allOf, so object schemas list all of their
+// properties.
+func (spec openAPI) resolve(s *schema) *schema {
+	if s.Ref != "" {
+		return spec.resolve(spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")])
+	}
+	if len(s.AllOf) == 0 {
+		return s
+	}
+
+	merged := &schema{Type: "object", Properties: map[string]*schema{}}
+	for _, part := range s.AllOf {
+		part = spec.resolve(part)
+		merged.Required = append(merged.Required, part.Required...)
+		for name, prop := range part.Properties {
+			merged.Properties[name] = prop
+		}
+	}
+	return merged
+}
+
+// validate reports where value does not match the schema. Objects may not
+// have properties the schema does not document.
+func (spec openAPI) validate(value any, s *schema, path string) []string {
+	s = spec.resolve(s)
+
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{path + " is null"}
+	}
+
+	var errs []string
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, want an object", path, value)}
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s is missing", path, name))
+			}
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			switch {
+			case ok:
+				errs = append(errs, spec.validate(v, prop, path+"."+name)...)
+			case s.AdditionalProperties != nil:
+				errs = append(errs, spec.validate(v, s.AdditionalProperties, path+"."+name)...)
+			case s.Properties != nil:
+				errs = append(errs, fmt.Sprintf("%s.%s is not documented", path, name))
+			}
+		}
+
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, want an array", path, value)}
+		}
+		for i, item := range items {
+			errs = append(errs, spec.validate(item, s.Items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, want a string", path, value)}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			errs = append(errs, fmt.Sprintf("%s is %q, want one of %v", path, str, s.Enum))
+		}
+		switch s.Format {
+		case "uuid":
+			if _, err := uuid.Parse(str); err != nil {
+				errs = append(errs, fmt.Sprintf("%s is %q, want a uuid", path, str))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				errs = append(errs, fmt.Sprintf("%s is %q, want a date-time", path, str))
+			}
+		}
+
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, want a %s", path, value, s.Type)}
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			errs = append(errs, fmt.Sprintf("%s is %v, want an integer", path, n))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s is %T, want a boolean", path, value)}
+		}
+	}
+
+	return errs
+}
+
+// registeredRoutes returns the patterns RegisterRoutes registers, read from
+// its source since ServeMux does not list them.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatalf("could not parse routes.go: %v", err)
+	}
+
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FuncDecl)
+		if ok && fn.Name.Name != "RegisterRoutes" {
+			return false
+		}
+
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") || len(call.Args) == 0 {
+			return true
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			pattern, _ := strconv.Unquote(lit.Value)
+			routes = append(routes, pattern)
+		}
+		return true
+	})
+
+	return routes
+}
+
+// specRoutes returns the documented operations as ServeMux patterns.
+func specRoutes(spec openAPI) []string {
+	var routes []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	return routes
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	registered := registeredRoutes(t)
+	if len(registered) == 0 {
+		t.Fatal("found no routes in RegisterRoutes")
+	}
+	documented := specRoutes(spec)
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is not documented in openapi.json", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("%s is documented but not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIErrorCodes(t *testing.T) {
+	spec := loadSpec(t)
+	documented := spec.Components.Schemas["Error"].Properties["code"].Enum
+
+	for code := range codeStatus {
+		if !slices.Contains(documented, string(code)) {
+			t.Errorf("error code %s is not documented", code)
+		}
+	}
+	for _, code := range documented {
+		if _, ok := codeStatus[ErrorCode(code)]; !ok {
+			t.Errorf("error code %s is documented but never sent", code)
+		}
+	}
+}
+
+// checkResponse validates a response against the documented responses of the
+// route.
+func (spec openAPI) checkResponse(route string, status int, header http.Header, body []byte) []string {
+	method, path, _ := strings.Cut(route, " ")
+	op, ok := spec.Paths[path][strings.ToLower(method)]
+	if !ok {
+		return []string{"the route is not documented"}
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return []string{fmt.Sprintf("status %d is not documented: %s", status, body)}
+	}
+	if resp.Ref != "" {
+		resp = spec.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+	}
+
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return []string{fmt.Sprintf("expected no body; got %q", body)}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return []string{fmt.Sprintf("bad Content-Type %q", header.Get("Content-Type"))}
+	}
+
+	var content *schema
+	for documented, c := range resp.Content {
+		if documented == mediaType || documented == "*/*" {
+			content = c.Schema
+		}
+	}
+	if content == nil {
+		return []string{fmt.Sprintf("Content-Type %s is not documented", mediaType)}
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %q", body)}
+	}
+	return spec.validate(value, content, "body")
+}
+
+// newFakeServer returns a server whose database is the fixture, with the
+// attachment and its thumbnail in storage.
+func newFakeServer(t *testing.T) (*Server, fixture) {
+	t.Helper()
+
+	hashed, err := auth.HashPassword("password")
+	if err != nil {
+		t.Fatalf("could not hash password: %v", err)
+	}
+	fx := newFixture(hashed)
+
+	store := storage.NewLocalStore(t.TempDir())
+	png := []byte("\x89PNG\r\n\x1a\n")
+	for _, key := range []string{fx.attachmentKey, fx.thumbnailKey} {
+		if err := store.Put(context.Background(), key, bytes.NewReader(png), int64(len(png)), "image/png"); err != nil {
+			t.Fatalf("could not store %s: %v", key, err)
+		}
+	}
+
+	s := &Server{
+		tokens:  auth.NewTokenIssuer("topSecret"),
+		db:      dbtest.New(fx.results()),
+		hub:     realtime.NewHub(),
+		storage: store,
+	}
+	s.presence = presence.NewTracker(s.publishTyping)
+	t.Cleanup(s.hub.Close)
+
+	return s, fx
+}
+
+func TestContract(t *testing.T) {
+	spec := loadSpec(t)
+
+	s, fx := newFakeServer(t)
+	handler := s.RegisterRoutes()
+
+	token, err := auth.MakeJWT(fx.me, "topSecret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	part, _ := form.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("remember the milk"))
+	form.WriteField("content", "notes")
+	form.Close()
+
+	room := "/api/chatrooms/" + fx.room.String()
+	message := room + "/messages/" + fx.message.String()
+	attachment := room + "/attachments/" + fx.attachment.String()
+	friend := fx.friend.String()
+
+	cases := []struct {
+		route   string
+		path    string
+		body    string
+		headers map[string]string
+		status  int
+	}{
+		{"POST /api/users", "", `{"email": "tako@example.com", "nickname": "tako", "password": "ink"}`, nil, 201},
+		{"POST /api/users", "", `{"email": "ika@example.com", "nickname": "ika", "password": "ink"}`, nil, 409},
+		{"GET /api/users", "/api/users?q=ta&limit=1", "", nil, 200},
+		{"GET /api/users/me", "", "", nil, 200},
+		{"GET /api/users/{userID}", "/api/users/" + friend, "", nil, 200},
+		{"PUT /api/users/{userID}", "/api/users/" + fx.me.String(), `{"nickname": "ika", "discoverable": false}`, nil, 200},
+		{"PUT /api/users/{userID}", "/api/users/" + friend, `{"nickname": "ika"}`, nil, 403},
+		{"POST /api/login", "", `{"email": "ika@example.com", "password": "password"}`, nil, 200},
+		{"POST /api/login", "", `{"email": "ika@example.com", "password": "wrong"}`, nil, 401},
+		{"POST /api/refresh", "", "", map[string]string{"Authorization": "ApiKey " + fx.refreshToken}, 200},
+		{"POST /api/refresh", "", "", map[string]string{"Authorization": "Bearer " + fx.refreshToken}, 401},
+		{"POST /api/revoke", "", "", map[string]string{"Authorization": "ApiKey " + fx.refreshToken}, 204},
+
+		{"GET /api/blocks", "", "", nil, 200},
+		{"POST /api/blocks", "", `{"user_id": "` + friend + `"}`, nil, 204},
+		{"DELETE /api/blocks/{userID}", "/api/blocks/" + friend, "", nil, 204},
+
+		{"GET /api/contacts", "", "", nil, 200},
+		{"GET /api/contacts", "", "", map[string]string{"Authorization": ""}, 401},
+		{"DELETE /api/contacts/{userID}", "/api/contacts/" + friend, "", nil, 204},
+		{"POST /api/contacts/requests", "", `{"user_id": "` + friend + `"}`, nil, 201},
+		{"POST /api/contacts/requests", "", `{"user_id": "nope"}`, nil, 422},
+		{"POST /api/contacts/requests/{userID}/accept", "/api/contacts/requests/" + friend + "/accept", "", nil, 204},
+		{"POST /api/contacts/requests/{userID}/decline", "/api/contacts/requests/" + friend + "/decline", "", nil, 204},
+		{"DELETE /api/contacts/requests/{userID}", "/api/contacts/requests/" + friend, "", nil, 204},
+
+		{"POST /api/chatrooms", "", `{"type": "direct", "friend_id": "` + friend + `"}`, nil, 200},
+		{"POST /api/chatrooms", "", `{"type": "group", "name": "crew", "participant_ids": ["` + friend + `"]}`, nil, 201},
+		{"POST /api/chatrooms", "", `{"type": "group"}`, nil, 422},
+		{"GET /api/chatrooms", "", "", nil, 200},
+		{"PATCH /api/chatrooms/{chatroomID}", room, `{"name": "new crew"}`, nil, 200},
+		{"DELETE /api/chatrooms/{chatroomID}", room, "", nil, 204},
+		{"DELETE /api/chatrooms/{chatroomID}", room + "?for=everyone", "", nil, 204},
+		{"DELETE /api/chatrooms/{chatroomID}", room + "?for=nobody", "", nil, 400},
+		{"POST /api/chatrooms/{chatroomID}/read", room + "/read", `{"message_id": "` + fx.message.String() + `"}`, nil, 204},
+		{"POST /api/chatrooms/{chatroomID}/typing", room + "/typing", "", nil, 204},
+		{"GET /api/chatrooms/{chatroomID}/participants", room + "/participants", "", nil, 200},
+		{"GET /api/chatrooms/{chatroomID}/participants", "/api/chatrooms/nope/participants", "", nil, 400},
+		{"POST /api/chatrooms/{chatroomID}/participants", room + "/participants", `{"participant_id": "` + fx.stranger.String() + `"}`, nil, 201},
+		{"POST /api/chatrooms/{chatroomID}/participants", room + "/participants", `{"participant_id": "` + friend + `"}`, nil, 409},
+		{"DELETE /api/chatrooms/{chatroomID}/participants/{userID}", room + "/participants/" + friend, "", nil, 204},
+		{"DELETE /api/chatrooms/{chatroomID}/participants/{userID}", room + "/participants/" + fx.stranger.String(), "", nil, 404},
+		{"PUT /api/chatrooms/{chatroomID}/participants/{userID}/role", room + "/participants/" + friend + "/role", `{"role": "admin"}`, nil, 200},
+
+		{"GET /api/chatrooms/{chatroomID}/messages", room + "/messages?limit=2", "", nil, 200},
+		{"GET /api/chatrooms/{chatroomID}/messages", room + "/messages?before=x&after=y", "", nil, 400},
+		{"POST /api/chatrooms/{chatroomID}/messages", room + "/messages", `{"content": "hello", "reply_to_id": "` + fx.message.String() + `"}`, nil, 201},
+		{"POST /api/chatrooms/{chatroomID}/messages", room + "/messages", `{"content": ""}`, nil, 422},
+		{"PATCH /api/chatrooms/{chatroomID}/messages/{messageID}", message, `{"content": "hello again"}`, nil, 200},
+		{"DELETE /api/chatrooms/{chatroomID}/messages/{messageID}", message, "", nil, 204},
+		{"GET /api/chatrooms/{chatroomID}/messages/{messageID}/thread", message + "/thread", "", nil, 200},
+		{"GET /api/chatrooms/{chatroomID}/messages/{messageID}/revisions", message + "/revisions", "", nil, 200},
+		{"POST /api/chatrooms/{chatroomID}/messages/{messageID}/reactions", message + "/reactions", `{"emoji": "🦑"}`, nil, 201},
+		{"DELETE /api/chatrooms/{chatroomID}/messages/{messageID}/reactions/{emoji}", message + "/reactions/🦑", "", nil, 204},
+
+		{"POST /api/chatrooms/{chatroomID}/attachments", room + "/attachments", upload.String(), map[string]string{"Content-Type": form.FormDataContentType()}, 201},
+		{"GET /api/chatrooms/{chatroomID}/attachments/{attachmentID}", attachment, "", nil, 200},
+		{"GET /api/chatrooms/{chatroomID}/attachments/{attachmentID}/thumbnail", attachment + "/thumbnail", "", nil, 200},
+
+		{"GET /api/search/messages", "/api/search/messages?q=sea", "", nil, 200},
+		{"GET /api/chatrooms/{chatroomID}/events", room + "/events", "", map[string]string{"Last-Event-ID": "1"}, 200},
+
+		{"GET /api/health", "", "", nil, 200},
+		{"GET /api/openapi.json", "", "", nil, 200},
+	}
+
+	succeeded := map[string]bool{}
+	for _, c := range cases {
+		method, path, _ := strings.Cut(c.route, " ")
+		if c.path != "" {
+			path = c.path
+		}
+
+		r := httptest.NewRequest(method, path, strings.NewReader(c.body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Content-Type", "application/json")
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+
+		// Event streams only end when the client goes away.
+		if strings.HasSuffix(c.route, "/events") {
+			ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if rec.Code != c.status {
+			t.Errorf("%s %s: expected %d; got %d %s", method, path, c.status, rec.Code, rec.Body)
+			continue
+		}
+		for _, problem := range spec.checkResponse(c.route, rec.Code, rec.Header(), rec.Body.Bytes()) {
+			t.Errorf("%s %s (%d): %s", method, path, rec.Code, problem)
+		}
+		if rec.Code < 300 {
+			succeeded[c.route] = true
+		}
+	}
+
+	// Websockets need a connection that can be hijacked.
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws?access_token="+token, nil)
+	if err != nil {
+		t.Errorf("GET /api/ws: %v", err)
+	} else {
+		conn.Close()
+		for _, problem := range spec.checkResponse("GET /api/ws", res.StatusCode, res.Header, nil) {
+			t.Errorf("GET /api/ws (%d): %s", res.StatusCode, problem)
+		}
+		succeeded["GET /api/ws"] = true
+	}
+
+	for _, route := range registeredRoutes(t) {
+		if !succeeded[route] {
+			t.Errorf("%s has no successful response checked against the spec", route)
+		}
+	}
+}
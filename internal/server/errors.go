@@ -0,0 +1,362 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fernandofreamunde/ika/internal/chatroom"
+	"github.com/fernandofreamunde/ika/internal/thumbnail"
+	"github.com/fernandofreamunde/ika/internal/user"
+	"github.com/google/uuid"
+)
+
+// MaxBodySize is the largest JSON body accepted. Attachments are uploaded as
+// multipart forms and have their own limit.
+const MaxBodySize = 1 << 20
+
+// ErrorCode identifies the kind of error. Clients match on it, so codes never
+// change once released; messages may.
+type ErrorCode string
+
+const (
+	CodeBadRequest         ErrorCode = "bad_request"
+	CodeInvalidJSON        ErrorCode = "invalid_json"
+	CodeBodyTooLarge       ErrorCode = "body_too_large"
+	CodeValidation         ErrorCode = "validation_failed"
+	CodeUnauthorized       ErrorCode = "unauthorized"
+	CodeInvalidCredentials ErrorCode = "invalid_credentials"
+	CodeForbidden          ErrorCode = "forbidden"
+	CodeNotParticipant     ErrorCode = "not_participant"
+	CodeBlocked            ErrorCode = "blocked"
+	CodeContactsOnly       ErrorCode = "contacts_only"
+	CodeUserNotFound       ErrorCode = "user_not_found"
+	CodeChatroomNotFound   ErrorCode = "chatroom_not_found"
+	CodeParticipantMissing ErrorCode = "participant_not_found"
+	CodeMessageNotFound    ErrorCode = "message_not_found"
+	CodeMessageDeleted     ErrorCode = "message_deleted"
+	CodeAttachmentNotFound ErrorCode = "attachment_not_found"
+	CodeRequestNotFound    ErrorCode = "friend_request_not_found"
+	CodeNotContacts        ErrorCode = "not_contacts"
+	CodeNotBlocked         ErrorCode = "not_blocked"
+	CodeEmailTaken         ErrorCode = "email_taken"
+	CodeAlreadyContacts    ErrorCode = "already_contacts"
+	CodeRequestExists      ErrorCode = "friend_request_exists"
+	CodeAlreadyParticipant ErrorCode = "already_participant"
+	CodeDirectChatroom     ErrorCode = "direct_chatroom"
+	CodeAttachmentTooLarge ErrorCode = "attachment_too_large"
+	CodeInternal           ErrorCode = "internal_error"
+	CodeUnavailable        ErrorCode = "service_unavailable"
+)
+
+var codeStatus = map[ErrorCode]int{
+	CodeBadRequest:         400,
+	CodeInvalidJSON:        400,
+	CodeBodyTooLarge:       413,
+	CodeValidation:         422,
+	CodeUnauthorized:       401,
+	CodeInvalidCredentials: 401,
+	CodeForbidden:          403,
+	CodeNotParticipant:     403,
+	CodeBlocked:            403,
+	CodeContactsOnly:       403,
+	CodeUserNotFound:       404,
+	CodeChatroomNotFound:   404,
+	CodeParticipantMissing: 404,
+	CodeMessageNotFound:    404,
+	CodeMessageDeleted:     404,
+	CodeAttachmentNotFound: 404,
+	CodeRequestNotFound:    404,
+	CodeNotContacts:        404,
+	CodeNotBlocked:         404,
+	CodeEmailTaken:         409,
+	CodeAlreadyContacts:    409,
+	CodeRequestExists:      409,
+	CodeAlreadyParticipant: 409,
+	CodeDirectChatroom:     422,
+	CodeAttachmentTooLarge: 413,
+	CodeInternal:           500,
+	CodeUnavailable:        503,
+}
+
+// Status is the HTTP status code errors with this code are sent with.
+func (c ErrorCode) Status() int {
+	if status, ok := codeStatus[c]; ok {
+		return status
+	}
+	return 500
+}
+
+// FieldError points at the part of the request that was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError is the body of every error response.
+type APIError struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func (e APIError) Error() string {
+	return e.Message
+}
+
+// domainErrors maps the errors of the domain packages to the code they are
+// reported with; Field is set when the error is about one request field.
+var domainErrors = []struct {
+	Err   error
+	Code  ErrorCode
+	Field string
+}{
+	{user.ErrUserNotFound, CodeUserNotFound, ""},
+	{user.ErrEmailTaken, CodeEmailTaken, "email"},
+	{user.ErrMissingFields, CodeValidation, ""},
+	{user.ErrBlockSelf, CodeValidation, "user_id"},
+	{user.ErrNotBlocked, CodeNotBlocked, ""},
+	{user.ErrBlocked, CodeBlocked, ""},
+	{user.ErrFriendSelf, CodeValidation, "user_id"},
+	{user.ErrAlreadyContacts, CodeAlreadyContacts, ""},
+	{user.ErrRequestExists, CodeRequestExists, ""},
+	{user.ErrRequestNotFound, CodeRequestNotFound, ""},
+	{user.ErrNotContacts, CodeNotContacts, ""},
+	{user.ErrContactsOnly, CodeContactsOnly, ""},
+	{user.ErrEmptyUserSearch, CodeBadRequest, "q"},
+	{chatroom.ErrDirectChatroom, CodeDirectChatroom, ""},
+	{chatroom.ErrAlreadyParticipant, CodeAlreadyParticipant, ""},
+	{chatroom.ErrNotParticipant, CodeNotParticipant, ""},
+	{chatroom.ErrDirectSelf, CodeValidation, "friend_id"},
+	{chatroom.ErrNameRequired, CodeValidation, "name"},
+	{chatroom.ErrForbidden, CodeForbidden, ""},
+	{chatroom.ErrInvalidRole, CodeValidation, "role"},
+	{chatroom.ErrMessageNotFound, CodeMessageNotFound, ""},
+	{chatroom.ErrMessageDeleted, CodeMessageDeleted, ""},
+	{chatroom.ErrReplyOtherChatroom, CodeValidation, "reply_to_id"},
+	{chatroom.ErrEmptyContent, CodeValidation, "content"},
+	{chatroom.ErrInvalidEmoji, CodeValidation, "emoji"},
+	{chatroom.ErrEmptySearch, CodeBadRequest, "q"},
+	{chatroom.ErrAttachmentNotFound, CodeAttachmentNotFound, ""},
+	{chatroom.ErrAttachmentTooLarge, CodeAttachmentTooLarge, "file"},
+	{thumbnail.ErrInvalidImage, CodeValidation, "file"},
+	{thumbnail.ErrImageTooLarge, CodeValidation, "file"},
+	{errEmptyBody, CodeInvalidJSON, ""},
+}
+
+// errParticipantMissing is reported when the user acted upon, rather than the
+// one acting, does not participate in the chatroom.
+var errParticipantMissing = APIError{Code: CodeParticipantMissing, Message: chatroom.ErrNotParticipant.Error()}
+
+// errReplyNotFound is reported instead of chatroom.ErrMessageNotFound when the
+// missing message is the one replied to.
+var errReplyNotFound = invalidField("reply_to_id", "The message replied to was not found.")
+
+// toAPIError turns err into the APIError it is reported as. Errors the domain
+// packages do not export are internal, their details are only logged.
+func toAPIError(err error) APIError {
+
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, known := range domainErrors {
+		if !errors.Is(err, known.Err) {
+			continue
+		}
+		apiErr = APIError{Code: known.Code, Message: known.Err.Error()}
+		if known.Field != "" {
+			apiErr.Fields = []FieldError{{Field: known.Field, Message: known.Err.Error()}}
+		}
+		return apiErr
+	}
+
+	return APIError{Code: CodeInternal, Message: "Internal server error."}
+}
+
+// respondError replies with err as an APIError, logging the errors that are
+// not the client's fault.
+func respondError(err error, w http.ResponseWriter, r *http.Request) {
+
+	apiErr := toAPIError(err)
+	apiErr.RequestID = requestID(r.Context())
+
+	status := apiErr.Code.Status()
+	if status >= 500 {
+		log.Printf("[%s] %s %s: %v", apiErr.RequestID, r.Method, r.URL.Path, err)
+	}
+
+	respondWithJson(apiErr, status, w)
+}
+
+// respondCode replies with an error that has no domain error behind it.
+func respondCode(code ErrorCode, message string, w http.ResponseWriter, r *http.Request) {
+	respondError(APIError{Code: code, Message: message}, w, r)
+}
+
+// invalidField is the error for one rejected request field.
+func invalidField(field, message string) APIError {
+	return APIError{
+		Code:    CodeValidation,
+		Message: message,
+		Fields:  []FieldError{{Field: field, Message: message}},
+	}
+}
+
+// invalidParam is the error for a malformed query parameter.
+func invalidParam(name, message string) APIError {
+	return APIError{
+		Code:    CodeBadRequest,
+		Message: message,
+		Fields:  []FieldError{{Field: name, Message: message}},
+	}
+}
+
+// requireFields returns a validation error listing the fields left empty.
+func requireFields(fields map[string]string) error {
+
+	var missing []FieldError
+	for name, value := range fields {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, FieldError{Field: name, Message: name + " is required."})
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Map order is random, keep the response stable.
+	slices.SortFunc(missing, func(a, b FieldError) int { return strings.Compare(a.Field, b.Field) })
+	return APIError{Code: CodeValidation, Message: "Some required fields are missing.", Fields: missing}
+}
+
+// errEmptyBody is returned by decodeJSON for requests without a body, which
+// some handlers accept.
+var errEmptyBody = errors.New("Request body can not be empty.")
+
+// decodeJSON strictly decodes the JSON request body into dst: unknown fields,
+// trailing data and bodies over MaxBodySize are rejected.
+func decodeJSON(dst any, w http.ResponseWriter, r *http.Request) error {
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return APIError{Code: CodeInvalidJSON, Message: "Request body must contain a single JSON object."}
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.As(err, &tooLarge):
+		return APIError{Code: CodeBodyTooLarge, Message: fmt.Sprintf("Request body can not be larger than %d bytes.", tooLarge.Limit)}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return APIError{Code: CodeInvalidJSON, Message: "Request body is not valid JSON."}
+	case errors.As(err, &typeErr):
+		message := fmt.Sprintf("%s must be a %s.", typeErr.Field, jsonType(typeErr.Type.Kind().String()))
+		return APIError{
+			Code:    CodeInvalidJSON,
+			Message: message,
+			Fields:  []FieldError{{Field: typeErr.Field, Message: message}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		message := fmt.Sprintf("%s is not a known field.", field)
+		return APIError{
+			Code:    CodeInvalidJSON,
+			Message: message,
+			Fields:  []FieldError{{Field: field, Message: message}},
+		}
+	default:
+		return APIError{Code: CodeInvalidJSON, Message: "Request body is not valid JSON."}
+	}
+}
+
+// jsonType names a Go kind the way the JSON it is decoded from is called.
+func jsonType(kind string) string {
+	switch {
+	case kind == "string":
+		return "string"
+	case kind == "bool":
+		return "boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// parseID parses the UUID in the named path value.
+func parseID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.Nil, APIError{Code: CodeBadRequest, Message: fmt.Sprintf("%s must be a valid ID.", name)}
+	}
+	return id, nil
+}
+
+// parseBodyID parses the UUID sent in a request field.
+func parseBodyID(field, value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, invalidField(field, field+" is required.")
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, invalidField(field, field+" must be a valid ID.")
+	}
+	return id, nil
+}
+
+// findChatroomError tells a chatroom that does not exist from a failing query.
+func findChatroomError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIError{Code: CodeChatroomNotFound, Message: "Chatroom not found."}
+	}
+	return fmt.Errorf("Err finding chatroom: %v", err)
+}
+
+type requestIDKey struct{}
+
+// requestID returns the ID of the request the context belongs to.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware tags every request with an ID, the one sent by the
+// client or proxy in X-Request-ID when there is a sane one, and echoes it back.
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if len(id) == 0 || len(id) > 64 || strings.ContainsFunc(id, func(c rune) bool { return c < '!' || c > '~' }) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
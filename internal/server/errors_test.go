@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/internal/chatroom"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/fernandofreamunde/ika/internal/user"
+	"github.com/google/uuid"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type Parameters struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	cases := []struct {
+		body  string
+		code  ErrorCode
+		field string
+	}{
+		{`{"name": "ika", "count": 2}`, "", ""},
+		{``, CodeInvalidJSON, ""},
+		{`{"name": `, CodeInvalidJSON, ""},
+		{`{"name": "ika"} {"name": "again"}`, CodeInvalidJSON, ""},
+		{`{"nickname": "ika"}`, CodeInvalidJSON, "nickname"},
+		{`{"count": "two"}`, CodeInvalidJSON, "count"},
+		{`{"name": "` + strings.Repeat("a", MaxBodySize) + `"}`, CodeBodyTooLarge, ""},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		params := Parameters{}
+		err := decodeJSON(&params, httptest.NewRecorder(), r)
+
+		if c.code == "" {
+			if err != nil || params.Name != "ika" || params.Count != 2 {
+				t.Errorf("expected %q to decode; got %+v, %v", c.body, params, err)
+			}
+			continue
+		}
+
+		apiErr := toAPIError(err)
+		if apiErr.Code != c.code {
+			t.Errorf("expected %q to fail with %s; got %+v", c.body[:min(len(c.body), 40)], c.code, apiErr)
+		}
+		if c.field != "" && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != c.field) {
+			t.Errorf("expected %q to point at %s; got %+v", c.body, c.field, apiErr.Fields)
+		}
+	}
+}
+
+func TestToAPIError(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   ErrorCode
+		status int
+	}{
+		{user.ErrUserNotFound, CodeUserNotFound, 404},
+		{chatroom.ErrNotParticipant, CodeNotParticipant, 403},
+		{chatroom.ErrAlreadyParticipant, CodeAlreadyParticipant, 409},
+		{chatroom.ErrEmptyContent, CodeValidation, 422},
+		{fmt.Errorf("Err sending message: %w", user.ErrBlocked), CodeBlocked, 403},
+		{fmt.Errorf("Err finding chatroom: connection refused"), CodeInternal, 500},
+	}
+
+	for _, c := range cases {
+		apiErr := toAPIError(c.err)
+		if apiErr.Code != c.code || apiErr.Code.Status() != c.status {
+			t.Errorf("expected %v to be %s (%d); got %s (%d)", c.err, c.code, c.status, apiErr.Code, apiErr.Code.Status())
+		}
+	}
+
+	// Internal details never reach the client.
+	if apiErr := toAPIError(fmt.Errorf("pq: password authentication failed")); strings.Contains(apiErr.Message, "password") {
+		t.Errorf("internal error leaked: %q", apiErr.Message)
+	}
+}
+
+func TestRequireFields(t *testing.T) {
+	err := requireFields(map[string]string{"password": "", "email": " ", "nickname": "ika"})
+
+	apiErr := toAPIError(err)
+	if apiErr.Code != CodeValidation || len(apiErr.Fields) != 2 {
+		t.Fatalf("expected two missing fields; got %+v", apiErr)
+	}
+	if apiErr.Fields[0].Field != "email" || apiErr.Fields[1].Field != "password" {
+		t.Errorf("expected fields in order; got %+v", apiErr.Fields)
+	}
+
+	if err := requireFields(map[string]string{"email": "ika@example.com"}); err != nil {
+		t.Errorf("expected no error; got %v", err)
+	}
+}
+
+// request sends an authenticated request through the routes and decodes the
+// error it is answered with.
+func request(t *testing.T, s *Server, method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, APIError) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rec, r)
+
+	var apiErr APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("error response is not JSON: %q", rec.Body.String())
+	}
+
+	return rec, apiErr
+}
+
+func TestErrorResponses(t *testing.T) {
+	s := &Server{tokens: auth.NewTokenIssuer("topSecret"), presence: presence.NewTracker(nil)}
+
+	userID := uuid.New()
+	token, err := auth.MakeJWT(userID, "topSecret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+	authorized := map[string]string{"Authorization": "Bearer " + token}
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		status  int
+		code    ErrorCode
+	}{
+		{"no token", "GET", "/api/contacts", "", nil, 401, CodeUnauthorized},
+		{"malformed path ID", "GET", "/api/chatrooms/nope/participants", "", authorized, 400, CodeBadRequest},
+		{"other user", "PUT", "/api/users/" + uuid.NewString(), `{}`, authorized, 403, CodeForbidden},
+		{"unknown field", "POST", "/api/contacts/requests", `{"user": "x"}`, authorized, 400, CodeInvalidJSON},
+		{"malformed body ID", "POST", "/api/blocks", `{"user_id": "x"}`, authorized, 422, CodeValidation},
+		{"missing fields", "POST", "/api/users", `{"email": "ika@example.com"}`, nil, 422, CodeValidation},
+		{"bad limit", "GET", "/api/search/messages?q=hi&limit=0", "", authorized, 400, CodeBadRequest},
+		{"bad cursor", "GET", "/api/users?q=ika&after=nope", "", authorized, 400, CodeBadRequest},
+	}
+
+	for _, c := range cases {
+		rec, apiErr := request(t, s, c.method, c.path, c.body, c.headers)
+
+		if rec.Code != c.status || apiErr.Code != c.code {
+			t.Errorf("%s: expected %d %s; got %d %+v", c.name, c.status, c.code, rec.Code, apiErr)
+		}
+		if apiErr.Message == "" {
+			t.Errorf("%s: expected a message", c.name)
+		}
+		if apiErr.RequestID == "" || apiErr.RequestID != rec.Header().Get("X-Request-ID") {
+			t.Errorf("%s: expected the request ID in the body and header; got %q and %q", c.name, apiErr.RequestID, rec.Header().Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	s := &Server{}
+
+	var seen string
+	handler := s.requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r.Context())
+	}))
+
+	for sent, keep := range map[string]bool{
+		"req-42":                  true,
+		"":                        false,
+		"has spaces":              false,
+		strings.Repeat("x", 65):   false,
+		"0190d6f5-5d3f-7c6e-a1b2": true,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-ID", sent)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if seen == "" || rec.Header().Get("X-Request-ID") != seen {
+			t.Errorf("expected the request ID %q to be echoed; got %q", seen, rec.Header().Get("X-Request-ID"))
+		}
+		if keep != (seen == sent) {
+			t.Errorf("request ID %q kept: %v; want %v", sent, seen == sent, keep)
+		}
+	}
+}
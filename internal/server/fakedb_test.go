@@ -0,0 +1,167 @@
+package server
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+// fixture is the data the fake database holds: the current user owns a group
+// chatroom they share with a friend, where they sent a message with an image
+// attached.
+type fixture struct {
+	me, friend, stranger uuid.UUID
+	email, password      string
+	hashedPassword       string
+	room                 uuid.UUID
+	message              uuid.UUID
+	attachment           uuid.UUID
+	attachmentKey        string
+	thumbnailKey         string
+	refreshToken         string
+}
+
+func newFixture(hashedPassword string) fixture {
+	attachment := uuid.New()
+	return fixture{
+		me:             uuid.New(),
+		friend:         uuid.New(),
+		stranger:       uuid.New(),
+		email:          "ika@example.com",
+		password:       "password",
+		hashedPassword: hashedPassword,
+		room:           uuid.New(),
+		message:        uuid.New(),
+		attachment:     attachment,
+		attachmentKey:  "attachments/" + attachment.String(),
+		thumbnailKey:   "thumbnails/" + attachment.String(),
+		refreshToken:   "refresh-token",
+	}
+}
+
+func (fx fixture) results() map[string]dbtest.Result {
+	now := time.Now().UTC()
+	earlier := now.Add(-time.Hour)
+
+	userRow := func(id uuid.UUID, nickname string) []driver.Value {
+		return []driver.Value{id.String(), earlier, earlier, fx.hashedPassword, nickname, nickname + "@example.com", false, true}
+	}
+	me := []driver.Value{fx.me.String(), earlier, earlier, fx.hashedPassword, "ika", fx.email, false, true}
+	friend := userRow(fx.friend, "tako")
+	room := []driver.Value{fx.room.String(), earlier, earlier, "group", "crew"}
+	message := func(id uuid.UUID, msgType string, content any, replyTo any, updated time.Time) []driver.Value {
+		return []driver.Value{id.String(), earlier, updated, fx.me.String(), fx.room.String(), msgType, content, replyTo}
+	}
+	listed := append(message(fx.message, "image", "look", nil, earlier), int64(1), nil, nil, nil)
+	reply := append(message(uuid.New(), "text", "nice", fx.message.String(), earlier), int64(0), fx.me.String(), "image", "look")
+	attachment := []driver.Value{fx.attachment.String(), fx.message.String(), "sea.png", "image/png", int64(68), "c0ffee",
+		fx.attachmentKey, earlier, int64(1), int64(1), "ready", fx.thumbnailKey, int64(1), int64(1)}
+	contact := func(status string) []driver.Value {
+		return []driver.Value{fx.me.String(), fx.friend.String(), status, earlier, now}
+	}
+	with := func(row []driver.Value, extra ...driver.Value) []driver.Value {
+		return append(append([]driver.Value{}, row...), extra...)
+	}
+
+	return map[string]dbtest.Result{
+		"CreateUser": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], now, now, args[2], args[3], args[1], false, true})
+		},
+		"FindUserById": func(args []driver.Value) ([]string, [][]driver.Value) {
+			if args[0] == fx.me.String() {
+				return dbtest.Rows(me)
+			}
+			return dbtest.Rows(userRow(uuid.MustParse(args[0].(string)), "tako"))
+		},
+		"FindUserByEmail": func(args []driver.Value) ([]string, [][]driver.Value) {
+			if args[0] != fx.email {
+				return dbtest.Rows()
+			}
+			return dbtest.Rows(me)
+		},
+		"UpdateUser":       dbtest.One(me...),
+		"SearchUsers":      dbtest.One(friend...),
+		"SearchUsersAfter": dbtest.One(friend...),
+
+		"CreateRefreshToken": dbtest.One(fx.refreshToken, now, now, now.Add(time.Hour), nil, fx.me.String()),
+		"GetRefreshToken": func(args []driver.Value) ([]string, [][]driver.Value) {
+			if args[0] != fx.refreshToken {
+				return dbtest.Rows()
+			}
+			return dbtest.Rows([]driver.Value{fx.refreshToken, now, now, now.Add(time.Hour), nil, fx.me.String()})
+		},
+
+		"FindBlockedUsers":                  dbtest.One(with(friend, now)...),
+		"HasBlocked":                        dbtest.One(false),
+		"IsBlockedBetween":                  dbtest.One(false),
+		"AreContacts":                       dbtest.One(true),
+		"FindContactsByUserId":              dbtest.One(with(friend, fx.me.String(), "accepted", earlier)...),
+		"CreateFriendRequest":               dbtest.One(contact("pending")...),
+		"AcceptFriendRequest":               dbtest.One(contact("accepted")...),
+		"ClaimDirectChatroom":               dbtest.One(int64(1)),
+		"FindDirectChatroom":                dbtest.One(fx.room.String(), earlier, earlier, "direct", nil),
+		"FindChatRoomById":                  dbtest.One(room...),
+		"FindUsersChatrooms":                dbtest.One(room...),
+		"UpdateChatroom":                    dbtest.One(fx.room.String(), earlier, now, "group", "new crew"),
+		"FindUsersChatroomsWithUnreadCount": dbtest.One(with(room, fx.message.String(), earlier, int64(2))...),
+		"CreateChatroom": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], now, now, args[1], args[2]})
+		},
+		"FindParticipantRole": func(args []driver.Value) ([]string, [][]driver.Value) {
+			switch args[1] {
+			case fx.me.String():
+				return dbtest.Rows([]driver.Value{"owner"})
+			case fx.friend.String():
+				return dbtest.Rows([]driver.Value{"member"})
+			}
+			return dbtest.Rows()
+		},
+		"FindParticipantIdsByChatRoomId": func([]driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows(
+				[]driver.Value{fx.room.String(), fx.me.String(), "owner", nil, nil, earlier},
+				[]driver.Value{fx.room.String(), fx.friend.String(), "member", nil, nil, earlier},
+			)
+		},
+		"FindOwnerSuccessor": dbtest.One(fx.friend.String()),
+		"ShareChatroom":      dbtest.One(true),
+		"FindParticipantsByChatRoomId": func([]driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows(with(me, "owner"), with(friend, "member"))
+		},
+		"FindParticipantsReadPositions": dbtest.One(fx.friend.String(), now, fx.message.String()),
+
+		"CreateMessage": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], now, now, args[3], args[4], args[1], args[2], args[5]})
+		},
+		"FindMessageById": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows(message(uuid.MustParse(args[0].(string)), "text", "hello", nil, earlier))
+		},
+		"UpdateMessage":    dbtest.One(message(fx.message, "text", "hello again", nil, now)...),
+		"TombstoneMessage": dbtest.One(message(fx.message, "deleted", nil, nil, now)...),
+		"FindRepliesByMessageId": func([]driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows(message(uuid.New(), "text", "nice", fx.message.String(), earlier))
+		},
+		"FindMessagesByRoomBefore":        func([]driver.Value) ([]string, [][]driver.Value) { return dbtest.Rows(reply, listed) },
+		"FindMessagesByRoomAfter":         func([]driver.Value) ([]string, [][]driver.Value) { return dbtest.Rows(listed, reply) },
+		"FindMessagesByRoomById":          func([]driver.Value) ([]string, [][]driver.Value) { return dbtest.Rows(listed) },
+		"CreateMessageRevision":           dbtest.One(uuid.NewString(), fx.message.String(), fx.me.String(), "hello", now),
+		"FindRevisionsByMessageId":        dbtest.One(uuid.NewString(), fx.message.String(), fx.me.String(), "hello", earlier),
+		"FindReactionCountsByRoomBetween": dbtest.One(fx.message.String(), "🦑", int64(2), true),
+
+		"CreateChatroomEvent": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], int64(3), args[1], args[2], args[3], now})
+		},
+		"FindChatroomEventSeq":    dbtest.One(int64(2)),
+		"FindChatroomEventsAfter": dbtest.One(fx.room.String(), int64(2), "message.updated", fx.message.String(), []byte(`{"content":"missed"}`), now),
+
+		"CreateAttachment": func(args []driver.Value) ([]string, [][]driver.Value) {
+			return dbtest.Rows([]driver.Value{args[0], args[1], args[2], args[3], args[4], args[5], args[6], now, args[7], args[8], args[9], nil, nil, nil})
+		},
+		"FindAttachmentById":           dbtest.One(attachment...),
+		"FindAttachmentsByRoomBetween": dbtest.One(attachment...),
+
+		"SearchMessages":       dbtest.One(fx.message.String(), fx.room.String(), fx.me.String(), earlier, "look at the \x02sea\x03"),
+		"SearchMessagesBefore": dbtest.One(fx.message.String(), fx.room.String(), fx.me.String(), earlier, "look at the \x02sea\x03"),
+	}
+}
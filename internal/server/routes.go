@@ -1,40 +1,96 @@
 package server
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fernandofreamunde/ika/internal/auth"
 	"github.com/fernandofreamunde/ika/internal/chatroom"
 	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/realtime"
+	"github.com/fernandofreamunde/ika/internal/storage"
 	"github.com/fernandofreamunde/ika/internal/user"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Authentication is done with a bearer token rather than cookies, so
+	// cross origin connections are fine, same as the CORS policy.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
 func (s *Server) RegisterRoutes() http.Handler {
 	mux := http.NewServeMux()
 
 	// Register routes
 	//mux.HandleFunc("GET /", s.HelloWorldHandler)
 	mux.HandleFunc("POST /api/users", s.RegisterUserHandler)
+	mux.Handle("GET /api/users", s.authMiddleware(http.HandlerFunc(s.SearchUsersHandler)))
+	mux.Handle("GET /api/users/me", s.authMiddleware(http.HandlerFunc(s.GetAccountHandler)))
+	mux.Handle("GET /api/users/{userID}", s.authMiddleware(http.HandlerFunc(s.GetUserHandler)))
 	mux.Handle("PUT /api/users/{userID}", s.authMiddleware(http.HandlerFunc(s.UpdateUserHandler)))
 	mux.HandleFunc("POST /api/login", s.LoginHandler)
 	mux.HandleFunc("POST /api/refresh", s.RefreshLoginHandler)
 	mux.HandleFunc("POST /api/revoke", s.RevokeLoginHandler)
 
+	mux.Handle("GET /api/blocks", s.authMiddleware(http.HandlerFunc(s.GetBlockedUsersHandler)))
+	mux.Handle("POST /api/blocks", s.authMiddleware(http.HandlerFunc(s.BlockUserHandler)))
+	mux.Handle("DELETE /api/blocks/{userID}", s.authMiddleware(http.HandlerFunc(s.UnblockUserHandler)))
+
+	mux.Handle("GET /api/contacts", s.authMiddleware(http.HandlerFunc(s.GetContactsHandler)))
+	mux.Handle("DELETE /api/contacts/{userID}", s.authMiddleware(http.HandlerFunc(s.RemoveContactHandler)))
+	mux.Handle("POST /api/contacts/requests", s.authMiddleware(http.HandlerFunc(s.SendFriendRequestHandler)))
+	mux.Handle("POST /api/contacts/requests/{userID}/accept", s.authMiddleware(http.HandlerFunc(s.AcceptFriendRequestHandler)))
+	mux.Handle("POST /api/contacts/requests/{userID}/decline", s.authMiddleware(http.HandlerFunc(s.DeclineFriendRequestHandler)))
+	mux.Handle("DELETE /api/contacts/requests/{userID}", s.authMiddleware(http.HandlerFunc(s.CancelFriendRequestHandler)))
+
 	mux.Handle("POST /api/chatrooms", s.authMiddleware(http.HandlerFunc(s.CreateChatroomHandler)))
 	mux.Handle("GET /api/chatrooms", s.authMiddleware(http.HandlerFunc(s.GetChatroomsHandler)))
-	mux.Handle("DELETE /api/chatrooms/{chatroomID}", s.authMiddleware(http.HandlerFunc(s.LeaveChatroomHandler)))
+	mux.Handle("PATCH /api/chatrooms/{chatroomID}", s.authMiddleware(http.HandlerFunc(s.RenameChatroomHandler)))
+	mux.Handle("DELETE /api/chatrooms/{chatroomID}", s.authMiddleware(http.HandlerFunc(s.RemoveChatroomHandler)))
+
+	mux.Handle("POST /api/chatrooms/{chatroomID}/read", s.authMiddleware(http.HandlerFunc(s.MarkChatroomReadHandler)))
+	mux.Handle("POST /api/chatrooms/{chatroomID}/typing", s.authMiddleware(http.HandlerFunc(s.TypingHandler)))
+	mux.Handle("GET /api/chatrooms/{chatroomID}/participants", s.authMiddleware(http.HandlerFunc(s.GetParticipantsHandler)))
+	mux.Handle("POST /api/chatrooms/{chatroomID}/participants", s.authMiddleware(http.HandlerFunc(s.AddParticipantHandler)))
+	mux.Handle("DELETE /api/chatrooms/{chatroomID}/participants/{userID}", s.authMiddleware(http.HandlerFunc(s.RemoveParticipantHandler)))
+	mux.Handle("PUT /api/chatrooms/{chatroomID}/participants/{userID}/role", s.authMiddleware(http.HandlerFunc(s.UpdateParticipantRoleHandler)))
 
 	mux.Handle("GET /api/chatrooms/{chatroomID}/messages", s.authMiddleware(http.HandlerFunc(s.ReadMessagesHandler)))
 	mux.Handle("POST /api/chatrooms/{chatroomID}/messages", s.authMiddleware(http.HandlerFunc(s.CreateMessageHandler)))
+	mux.Handle("PATCH /api/chatrooms/{chatroomID}/messages/{messageID}", s.authMiddleware(http.HandlerFunc(s.EditMessageHandler)))
+	mux.Handle("DELETE /api/chatrooms/{chatroomID}/messages/{messageID}", s.authMiddleware(http.HandlerFunc(s.DeleteMessageHandler)))
+	mux.Handle("GET /api/chatrooms/{chatroomID}/messages/{messageID}/thread", s.authMiddleware(http.HandlerFunc(s.GetThreadHandler)))
+	mux.Handle("GET /api/chatrooms/{chatroomID}/messages/{messageID}/revisions", s.authMiddleware(http.HandlerFunc(s.GetMessageRevisionsHandler)))
+	mux.Handle("POST /api/chatrooms/{chatroomID}/messages/{messageID}/reactions", s.authMiddleware(http.HandlerFunc(s.AddReactionHandler)))
+	mux.Handle("DELETE /api/chatrooms/{chatroomID}/messages/{messageID}/reactions/{emoji}", s.authMiddleware(http.HandlerFunc(s.RemoveReactionHandler)))
+
+	mux.Handle("POST /api/chatrooms/{chatroomID}/attachments", s.authMiddleware(http.HandlerFunc(s.UploadAttachmentHandler)))
+	mux.Handle("GET /api/chatrooms/{chatroomID}/attachments/{attachmentID}", s.authMiddleware(http.HandlerFunc(s.DownloadAttachmentHandler)))
+	mux.Handle("GET /api/chatrooms/{chatroomID}/attachments/{attachmentID}/thumbnail", s.authMiddleware(http.HandlerFunc(s.DownloadThumbnailHandler)))
+
+	mux.Handle("GET /api/search/messages", s.authMiddleware(http.HandlerFunc(s.SearchMessagesHandler)))
+
+	mux.Handle("GET /api/chatrooms/{chatroomID}/events", s.queryTokenMiddleware(s.authMiddleware(http.HandlerFunc(s.ChatroomEventsHandler))))
+	mux.Handle("GET /api/ws", s.queryTokenMiddleware(s.authMiddleware(http.HandlerFunc(s.WebsocketHandler))))
 
 	mux.HandleFunc("GET /api/health", s.healthHandler)
+	mux.HandleFunc("GET /api/openapi.json", s.OpenAPIHandler)
 
 	// Wrap the mux with CORS middleware
-	return s.corsMiddleware(mux)
+	return s.requestIDMiddleware(s.corsMiddleware(mux))
 }
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
@@ -60,16 +116,29 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString, _ := auth.GetBearerToken(r.Header)
-		userId, err := auth.ValidateJWT(tokenString)
+		userId, err := s.tokens.ValidateJWT(tokenString)
 		if err != nil {
 			log.Printf("JWT check Failed: %v", err)
-			respondSimpleMessage("Unauthorized", 401, w)
+			respondCode(CodeUnauthorized, "Unauthorized.", w, r)
 			return
 		}
 
-		s.currentUserId = userId
+		s.presence.Touch(userId)
 
 		// Proceed with the next handler
+		next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), userId)))
+	})
+}
+
+// queryTokenMiddleware lets clients that can not set headers on the request,
+// like browsers opening a websocket or an EventSource, pass the JWT as the access_token query param.
+func (s *Server) queryTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("access_token")
+		if r.Header.Get("Authorization") == "" && token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -79,27 +148,63 @@ func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJson(resp, 200, w)
 }
 
+func (s *Server) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	u, err := user.ViewUser(currentUserId, r.PathValue("userID"), s.presence, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(u, 200, w)
+}
+
+// GetAccountHandler returns the current user's own record, email included.
+func (s *Server) GetAccountHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	account, err := user.GetAccount(currentUserId, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(account, 200, w)
+}
+
 func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 
-	userID, err := uuid.Parse(r.PathValue("userID"))
+	currentUserId := auth.MustUserID(r.Context())
+
+	userID, err := parseID(r, "userID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
 
-	if userID != s.currentUserId {
-		msg := "Can only edit own User Data."
-		log.Print(msg)
-		respondSimpleMessage(msg, 401, w)
+	if userID != currentUserId {
+		respondCode(CodeForbidden, "Users can only edit their own data.", w, r)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := user.UserParams{}
-	_ = decoder.Decode(&params)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	u, err := s.db.Queries().FindUserById(r.Context(), currentUserId)
+	if err != nil {
+		respondError(fmt.Errorf("Err finding user: %v", err), w, r)
+		return
+	}
 
-	u, _ := s.db.Queries().FindUserById(r.Context(), s.currentUserId)
 	resp, err := user.UpdateUser(u, params, r.Context(), s.db.Queries)
 	if err != nil {
-		resp := map[string]string{"message": err.Error()}
-		log.Println(err)
-		respondWithJson(resp, 422, w)
+		respondError(err, w, r)
 		return
 	}
 
@@ -108,15 +213,25 @@ func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
 
-	decoder := json.NewDecoder(r.Body)
 	params := user.UserParams{}
-	_ = decoder.Decode(&params)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err := requireFields(map[string]string{
+		"email":    params.Email,
+		"nickname": params.Nickname,
+		"password": params.Password,
+	})
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
 
 	resp, err := user.CreateUser(params, r.Context(), s.db.Queries)
 	if err != nil {
-		resp := map[string]string{"message": err.Error()}
-		log.Println(err)
-		respondWithJson(resp, 422, w)
+		respondError(err, w, r)
 		return
 	}
 
@@ -129,34 +244,45 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := Parameters{}
-	_ = decoder.Decode(&params)
-
-	dbUser, err := s.db.Queries().FindUserByEmail(r.Context(), params.Email)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
 
+	err := requireFields(map[string]string{
+		"email":    params.Email,
+		"password": params.Password,
+	})
 	if err != nil {
-		respondSimpleMessage("Incorrect email of password", 401, w)
+		respondError(err, w, r)
 		return
 	}
 
-	e := auth.CheckPasswordHash(dbUser.HashedPassword, params.Password)
-	if e != nil {
-		respondSimpleMessage("Incorrect email of password", 401, w)
+	dbUser, err := s.db.Queries().FindUserByEmail(r.Context(), params.Email)
+	if err == nil {
+		err = auth.CheckPasswordHash(dbUser.HashedPassword, params.Password)
+	}
+	if err != nil {
+		respondCode(CodeInvalidCredentials, "Incorrect email or password.", w, r)
 		return
 	}
 
-	resp, _ := auth.AuthenticateUser(dbUser, r.Context(), s.db.Queries)
+	resp, err := s.tokens.AuthenticateUser(dbUser, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err authenticating user: %v", err), w, r)
+		return
+	}
 
 	respondWithJson(resp, 200, w)
 }
 
 func (s *Server) RefreshLoginHandler(w http.ResponseWriter, r *http.Request) {
 
-	jwt, err := auth.RefreshJWT(r.Header, r.Context(), s.db.Queries)
+	jwt, err := s.tokens.RefreshJWT(r.Header, r.Context(), s.db.Queries)
 	if err != nil {
 		log.Printf("Unauthorized with error: %v", err)
-		respondSimpleMessage("Unauthorized.", 401, w)
+		respondCode(CodeUnauthorized, "Unauthorized.", w, r)
 		return
 	}
 
@@ -171,144 +297,1477 @@ func (s *Server) RefreshLoginHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) RevokeLoginHandler(w http.ResponseWriter, r *http.Request) {
 	auth.RevokeRefreshToken(r.Header, r.Context(), s.db.Queries)
-	respondSimpleMessage("", 204, w)
+	w.WriteHeader(204)
 }
 
-func (s *Server) CreateChatroomHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	query := r.URL.Query()
+	params := user.SearchUsersParams{
+		UserID: currentUserId,
+		Query:  query.Get("q"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			respondError(invalidParam("limit", "limit must be a positive number."), w, r)
+			return
+		}
+	}
+
+	if after := query.Get("after"); after != "" {
+		cursor, err := user.DecodeDirectoryCursor(after)
+		if err != nil {
+			respondError(invalidParam("after", err.Error()), w, r)
+			return
+		}
+		params.After = &cursor
+	}
+
+	page, err := user.SearchUsers(params, s.presence, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(page, 200, w)
+}
+
+func (s *Server) GetBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	blocked, err := user.FindBlockedUsers(currentUserId, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err finding blocked users: %v", err), w, r)
+		return
+	}
+
+	respondWithJson(blocked, 200, w)
+}
+
+func (s *Server) BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	type Parameters struct {
+		UserID string `json:"user_id"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	blocked, err := s.findBodyUser("user_id", params.UserID, r.Context())
+	if err == nil {
+		err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+			return user.BlockUser(currentUserId, blocked.ID, r.Context(), dbq)
+		})
+	}
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	blockedID, err := parseID(r, "userID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err = user.UnblockUser(currentUserId, blockedID, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) GetContactsHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	contacts, err := user.FindContacts(currentUserId, s.presence, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err finding contacts: %v", err), w, r)
+		return
+	}
+
+	respondWithJson(contacts, 200, w)
+}
+
+func (s *Server) SendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
 
 	type Parameters struct {
-		FriendID string `json:"friend_id"`
+		UserID string `json:"user_id"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := Parameters{}
-	_ = decoder.Decode(&params)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	addressee, err := s.findBodyUser("user_id", params.UserID, r.Context())
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
 
-	currentUser, _ := user.GetUserById(s.currentUserId.String(), r.Context(), s.db.Queries)
-	friend, err := user.GetUserById(params.FriendID, r.Context(), s.db.Queries)
+	status, err := user.SendFriendRequest(currentUserId, addressee.ID, r.Context(), s.db.Queries)
 	if err != nil {
-		log.Printf("Err Finding Fren: %v", err)
-		respondSimpleMessage("Friend not found.", 404, w)
+		respondError(err, w, r)
 		return
 	}
 
-	room, err := chatroom.CreateChatRoomWithParticipants(currentUser, friend, r.Context(), s.db.Queries) 
+	respondWithJson(user.Contact{
+		User:   addressee,
+		Status: status,
+		Since:  time.Now().UTC(),
+	}, 201, w)
+}
+
+// contactHandler runs one of the friend request or contact actions against the
+// user in the path.
+func (s *Server) contactHandler(action func(userId, otherId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error, w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	otherID, err := parseID(r, "userID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err = action(currentUserId, otherID, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) AcceptFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	s.contactHandler(user.AcceptFriendRequest, w, r)
+}
+
+func (s *Server) DeclineFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	s.contactHandler(user.DeclineFriendRequest, w, r)
+}
+
+func (s *Server) CancelFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	s.contactHandler(user.CancelFriendRequest, w, r)
+}
+
+func (s *Server) RemoveContactHandler(w http.ResponseWriter, r *http.Request) {
+	s.contactHandler(user.RemoveContact, w, r)
+}
+
+func (s *Server) CreateChatroomHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	type Parameters struct {
+		Type           string   `json:"type"`
+		FriendID       string   `json:"friend_id"`
+		Name           string   `json:"name"`
+		ParticipantIDs []string `json:"participant_ids"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	currentUser, err := user.GetUserById(currentUserId.String(), s.presence, r.Context(), s.db.Queries)
 	if err != nil {
-		log.Printf("Err Creating room with particpants: %v", err)
-		respondSimpleMessage("Internal Server Error.", 500, w)
+		respondError(fmt.Errorf("Err finding current user: %v", err), w, r)
+		return
+	}
+
+	var room db.Chatroom
+	var participants []user.User
+	// Direct chatrooms may already exist.
+	created := true
+
+	switch params.Type {
+	case "", chatroom.TypeDirect:
+		friend, err := s.findBodyUser("friend_id", params.FriendID, r.Context())
+		if err != nil {
+			respondError(err, w, r)
+			return
+		}
+
+		err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+			var err error
+			room, created, err = chatroom.GetOrCreateDirectChatroom(currentUser, friend, r.Context(), dbq)
+			return err
+		})
+		if err != nil {
+			respondError(err, w, r)
+			return
+		}
+		participants = []user.User{currentUser, friend}
+
+	case chatroom.TypeGroup:
+		if strings.TrimSpace(params.Name) == "" {
+			respondError(chatroom.ErrNameRequired, w, r)
+			return
+		}
+
+		members := make([]user.User, 0, len(params.ParticipantIDs))
+		for i, id := range params.ParticipantIDs {
+			member, err := s.findBodyUser(fmt.Sprintf("participant_ids[%d]", i), id, r.Context())
+			if err != nil {
+				respondError(err, w, r)
+				return
+			}
+			members = append(members, member)
+		}
+
+		err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+			var err error
+			room, err = chatroom.CreateGroupChatroom(params.Name, currentUser, members, r.Context(), dbq)
+			return err
+		})
+		if err != nil {
+			respondError(err, w, r)
+			return
+		}
+		participants = append(members, currentUser)
+
+	default:
+		respondError(invalidField("type", "Chatroom type must be direct or group."), w, r)
+		return
+	}
+
+	for _, p := range participants {
+		s.hub.Join(p.ID, room.ID)
+	}
+
+	if !created {
+		respondWithJson(chatroom.NewChatroom(room), 200, w)
 		return
 	}
-	respondWithJson(room, 201, w)
+
+	respondWithJson(chatroom.NewChatroom(room), 201, w)
 }
 
 func (s *Server) GetChatroomsHandler(w http.ResponseWriter, r *http.Request) {
 
-	rooms, err := s.db.Queries().FindUsersChatrooms(r.Context(), uuid.NullUUID{UUID: s.currentUserId, Valid: true})
+	currentUserId := auth.MustUserID(r.Context())
+
+	rooms, err := chatroom.FindUsersChatrooms(currentUserId, r.Context(), s.db.Queries)
 	if err != nil {
-		log.Printf("Err Geting users rooms: %v", err)
-		respondSimpleMessage("Internal Server Error.", 500, w)
+		respondError(fmt.Errorf("Err Geting users rooms: %v", err), w, r)
 		return
 	}
 
 	respondWithJson(rooms, 200, w)
 }
 
+// RemoveChatroomHandler leaves the chatroom, as DELETE always has, unless
+// for=everyone asks to delete it for all of its participants.
+func (s *Server) RemoveChatroomHandler(w http.ResponseWriter, r *http.Request) {
+
+	switch r.URL.Query().Get("for") {
+	case "":
+		s.LeaveChatroomHandler(w, r)
+	case "everyone":
+		s.DeleteChatroomHandler(w, r)
+	default:
+		respondCode(CodeBadRequest, "for can only be everyone.", w, r)
+	}
+}
+
 func (s *Server) LeaveChatroomHandler(w http.ResponseWriter, r *http.Request) {
 
-	roomID, err := uuid.Parse(r.PathValue("chatroomID"))
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
 	if err != nil {
-		log.Printf("Room ID not set!")
-		respondSimpleMessage("Bad Request", 400, w)
+		respondError(findChatroomError(err), w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "leave it", r.Context()); err != nil {
+		respondError(err, w, r)
 		return
 	}
 
-	err = s.db.Queries().ChatroomRemoveParticipant(r.Context(), db.ChatroomRemoveParticipantParams{
-		ChatroomID:    uuid.NullUUID{UUID: roomID, Valid: true},
-		ParticipantID: uuid.NullUUID{UUID: s.currentUserId, Valid: true},
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		return chatroom.LeaveChatroom(room, currentUserId, r.Context(), dbq)
 	})
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	s.hub.Leave(currentUserId, roomID)
 
-	respondSimpleMessage("deleted", 204, w)
+	w.WriteHeader(204)
 }
 
-func (s *Server) CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) RenameChatroomHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
 
-	roomID, err := uuid.Parse(r.PathValue("chatroomID"))
+	roomID, err := parseID(r, "chatroomID")
 	if err != nil {
-		log.Printf("Room ID not set!")
-		respondSimpleMessage("Bad Request", 400, w)
+		respondError(err, w, r)
 		return
 	}
 
 	type Parameters struct {
-		Content string `json:"content"`
+		Name string `json:"name"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := Parameters{}
-	_ = decoder.Decode(&params)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
 
-	isParticipant, err := chatroom.IsUserParticipantInChatroom(s.currentUserId, roomID, r.Context(), s.db.Queries)
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
 	if err != nil {
-		log.Printf("Err getting room participants: %v", err)
-		respondSimpleMessage("Chatroom not found.", 404, w)
+		respondError(findChatroomError(err), w, r)
 		return
 	}
 
-	if !isParticipant {
-		log.Printf("User is not a participant")
-		respondSimpleMessage("Use must participate in the chatroom to read messages", 401, w)
+	room, err = chatroom.RenameChatroom(room, currentUserId, params.Name, r.Context(), s.db.Queries)
+	switch {
+	case errors.Is(err, chatroom.ErrDirectChatroom):
+		respondCode(CodeDirectChatroom, "Direct chatrooms can not be renamed.", w, r)
+		return
+	case errors.Is(err, chatroom.ErrNotParticipant):
+		respondCode(CodeNotParticipant, "User must participate in the chatroom to rename it.", w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
 		return
 	}
 
-	msg, err := chatroom.SendMessageInChatroom(
-		chatroom.SendMessageParams{
-			AuthorID: s.currentUserId,
-			ChatroomID: roomID,
-			Content: params.Content,
-		}, 
-		r.Context(), 
-		s.db.Queries,
-	)
+	renamed := chatroom.NewChatroom(room)
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventChatroomUpdated,
+		ID:         room.ID,
+		ChatroomID: room.ID,
+		Data:       renamed,
+	})
+
+	respondWithJson(renamed, 200, w)
+}
+
+func (s *Server) DeleteChatroomHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
 
+	roomID, err := parseID(r, "chatroomID")
 	if err != nil {
-		log.Printf("Err creating message: %v", err)
-		respondSimpleMessage("Internal server error", 500, w)
+		respondError(err, w, r)
 		return
 	}
 
-	respondWithJson(msg, 201, w)
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
+	if err != nil {
+		respondError(findChatroomError(err), w, r)
+		return
+	}
+
+	participants, err := chatroom.FindParticipants(roomID, s.presence, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err getting room participants: %v", err), w, r)
+		return
+	}
+
+	var keys []string
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		var err error
+		keys, err = chatroom.DeleteChatroom(room, currentUserId, r.Context(), dbq)
+		return err
+	})
+	switch {
+	case errors.Is(err, chatroom.ErrDirectChatroom):
+		respondCode(CodeDirectChatroom, "Direct chatrooms can not be deleted, leave them instead.", w, r)
+		return
+	case errors.Is(err, chatroom.ErrNotParticipant):
+		respondCode(CodeNotParticipant, "User must participate in the chatroom to delete it.", w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	// The chatroom is gone either way, leftover blobs are only logged.
+	if err := chatroom.DeleteStoredFiles(keys, s.storage, r.Context()); err != nil {
+		log.Printf("Err deleting attachments: %v", err)
+	}
+
+	// The chatroom's event log went with it, so there is nothing to record
+	// this event in.
+	s.hub.Publish(realtime.Event{
+		Type:       realtime.EventChatroomDeleted,
+		ID:         room.ID,
+		ChatroomID: room.ID,
+	})
+	for _, p := range participants {
+		s.hub.Leave(p.ID, room.ID)
+	}
+
+	w.WriteHeader(204)
 }
 
-func (s *Server) ReadMessagesHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateParticipantRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
 
-	roomID, err := uuid.Parse(r.PathValue("chatroomID"))
+	participantID, err := parseID(r, "userID")
 	if err != nil {
-		log.Printf("Room ID not set!")
-		respondSimpleMessage("Bad Request", 400, w)
+		respondError(err, w, r)
 		return
 	}
 
 	type Parameters struct {
-		Content string `json:"content"`
+		Role string `json:"role"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := Parameters{}
-	_ = decoder.Decode(&params)
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
 
-	isParticipant, err := chatroom.IsUserParticipantInChatroom(s.currentUserId, roomID, r.Context(), s.db.Queries)
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
 	if err != nil {
-		log.Printf("Err getting room participants: %v", err)
-		respondSimpleMessage("Chatroom not found.", 404, w)
+		respondError(findChatroomError(err), w, r)
 		return
 	}
 
-	if !isParticipant {
-		log.Printf("User is not a participant")
-		respondSimpleMessage("Use must participate in the chatroom to read messages", 401, w)
+	if err := s.requireParticipant(currentUserId, roomID, "change roles", r.Context()); err != nil {
+		respondError(err, w, r)
 		return
 	}
 
-	msg, err := s.db.Queries().FindMessagesByRoomById(r.Context(), uuid.NullUUID{UUID: roomID, Valid: true})
-
-	respondWithJson(msg, 200, w)
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		return chatroom.SetParticipantRole(room, currentUserId, participantID, params.Role, r.Context(), dbq)
+	})
+	switch {
+	case errors.Is(err, chatroom.ErrNotParticipant):
+		respondError(errParticipantMissing, w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	respondSimpleMessage("Role updated.", 200, w)
+}
+
+func (s *Server) MarkChatroomReadHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		MessageID string `json:"message_id"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseBodyID("message_id", params.MessageID)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "read messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err = chatroom.MarkRead(currentUserId, roomID, messageID, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Receipt struct {
+		UserID    uuid.UUID `json:"user_id"`
+		MessageID uuid.UUID `json:"message_id"`
+	}
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventMessageRead,
+		ID:         messageID,
+		ChatroomID: roomID,
+		Data:       Receipt{UserID: currentUserId, MessageID: messageID},
+	})
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) TypingHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		Typing *bool `json:"typing"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil && !errors.Is(err, errEmptyBody) {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "type in it", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	// Without a body the user is typing.
+	if params.Typing == nil || *params.Typing {
+		s.presence.SetTyping(currentUserId, roomID)
+	} else {
+		s.presence.StopTyping(currentUserId, roomID)
+	}
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) GetParticipantsHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "see its participants", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	participants, err := chatroom.FindParticipants(roomID, s.presence, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err getting room participants: %v", err), w, r)
+		return
+	}
+
+	respondWithJson(participants, 200, w)
+}
+
+func (s *Server) AddParticipantHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		ParticipantID string `json:"participant_id"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
+	if err != nil {
+		respondError(findChatroomError(err), w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "add participants", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	participant, err := s.findBodyUser("participant_id", params.ParticipantID, r.Context())
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		return chatroom.AddParticipant(room, currentUserId, participant, r.Context(), dbq)
+	})
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	s.hub.Join(participant.ID, roomID)
+
+	respondWithJson(participant, 201, w)
+}
+
+func (s *Server) RemoveParticipantHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	participantID, err := parseID(r, "userID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
+	if err != nil {
+		respondError(findChatroomError(err), w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "remove participants", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		return chatroom.RemoveParticipant(room, currentUserId, participantID, r.Context(), dbq)
+	})
+	switch {
+	case errors.Is(err, chatroom.ErrNotParticipant):
+		respondError(errParticipantMissing, w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	s.hub.Leave(participantID, roomID)
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		Content   string `json:"content"`
+		ReplyToID string `json:"reply_to_id"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	var replyToID uuid.NullUUID
+	if params.ReplyToID != "" {
+		replyToID.UUID, err = uuid.Parse(params.ReplyToID)
+		if err != nil {
+			respondError(invalidField("reply_to_id", "reply_to_id must be a valid ID."), w, r)
+			return
+		}
+		replyToID.Valid = true
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "send messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	msg, err := chatroom.SendMessageInChatroom(
+		chatroom.SendMessageParams{
+			AuthorID: currentUserId,
+			ChatroomID: roomID,
+			Content: params.Content,
+			ReplyToID: replyToID,
+		}, 
+		r.Context(), 
+		s.db.Queries,
+	)
+
+	switch {
+	case errors.Is(err, chatroom.ErrMessageNotFound):
+		respondError(errReplyNotFound, w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	s.presence.StopTyping(currentUserId, roomID)
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventMessageCreated,
+		ID:         msg.ID,
+		ChatroomID: roomID,
+		Data:       msg,
+	})
+
+	respondWithJson(msg, 201, w)
+}
+
+func (s *Server) EditMessageHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		Content string `json:"content"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "edit messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	// The revision and the new content are saved together or not at all.
+	var msg chatroom.Message
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		var err error
+		msg, err = chatroom.EditMessage(
+			chatroom.EditMessageParams{
+				EditorID:   currentUserId,
+				ChatroomID: roomID,
+				MessageID:  messageID,
+				Content:    params.Content,
+			},
+			r.Context(),
+			dbq,
+		)
+		return err
+	})
+	switch {
+	case errors.Is(err, chatroom.ErrForbidden):
+		respondCode(CodeForbidden, "Only the author can edit a message.", w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventMessageUpdated,
+		ID:         msg.ID,
+		ChatroomID: roomID,
+		Data:       msg,
+	})
+
+	respondWithJson(msg, 200, w)
+}
+
+func (s *Server) DeleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "delete messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	var msg chatroom.Message
+	var keys []string
+	err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+		var err error
+		msg, err = chatroom.DeleteMessage(currentUserId, messageID, roomID, r.Context(), dbq)
+		if err != nil {
+			return err
+		}
+		keys, err = chatroom.DeleteAttachments(msg.ID, r.Context(), dbq)
+		return err
+	})
+	switch {
+	case errors.Is(err, chatroom.ErrForbidden):
+		respondCode(CodeForbidden, "Only the author and moderators can delete a message.", w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	// The message is gone either way, a leftover blob is only logged.
+	if err := chatroom.DeleteStoredFiles(keys, s.storage, r.Context()); err != nil {
+		log.Printf("Err deleting attachments: %v", err)
+	}
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventMessageDeleted,
+		ID:         msg.ID,
+		ChatroomID: roomID,
+		Data:       msg,
+	})
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) GetThreadHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "read messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	thread, err := chatroom.FindThread(messageID, roomID, currentUserId, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(thread, 200, w)
+}
+
+func (s *Server) GetMessageRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	revisions, err := chatroom.FindMessageRevisions(currentUserId, messageID, roomID, r.Context(), s.db.Queries)
+	switch {
+	case errors.Is(err, chatroom.ErrNotParticipant):
+		respondCode(CodeNotParticipant, "User must participate in the chatroom to see message revisions.", w, r)
+		return
+	case errors.Is(err, chatroom.ErrForbidden):
+		respondCode(CodeForbidden, "Only the author and moderators can see message revisions.", w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(revisions, 200, w)
+}
+
+func (s *Server) AddReactionHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	type Parameters struct {
+		Emoji string `json:"emoji"`
+	}
+	params := Parameters{}
+	if err := decodeJSON(&params, w, r); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "react to messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	reaction, err := chatroom.AddReaction(
+		chatroom.ReactionParams{
+			UserID:     currentUserId,
+			ChatroomID: roomID,
+			MessageID:  messageID,
+			Emoji:      params.Emoji,
+		},
+		r.Context(),
+		s.db.Queries,
+	)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventReactionAdded,
+		ID:         messageID,
+		ChatroomID: roomID,
+		Data:       reaction,
+	})
+
+	respondWithJson(reaction, 201, w)
+}
+
+func (s *Server) RemoveReactionHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	messageID, err := parseID(r, "messageID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "react to messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	reaction, err := chatroom.RemoveReaction(
+		chatroom.ReactionParams{
+			UserID:     currentUserId,
+			ChatroomID: roomID,
+			MessageID:  messageID,
+			Emoji:      r.PathValue("emoji"),
+		},
+		r.Context(),
+		s.db.Queries,
+	)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventReactionRemoved,
+		ID:         messageID,
+		ChatroomID: roomID,
+		Data:       reaction,
+	})
+
+	w.WriteHeader(204)
+}
+
+func (s *Server) ReadMessagesHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	params := chatroom.ListMessagesParams{ChatroomID: roomID, ViewerID: currentUserId}
+	query := r.URL.Query()
+
+	if limit := query.Get("limit"); limit != "" {
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			respondError(invalidParam("limit", "limit must be a positive number."), w, r)
+			return
+		}
+	}
+
+	if before := query.Get("before"); before != "" {
+		cursor, err := chatroom.DecodeCursor(before)
+		if err != nil {
+			respondError(invalidParam("before", err.Error()), w, r)
+			return
+		}
+		params.Before = &cursor
+	}
+
+	if after := query.Get("after"); after != "" {
+		cursor, err := chatroom.DecodeCursor(after)
+		if err != nil {
+			respondError(invalidParam("after", err.Error()), w, r)
+			return
+		}
+		params.After = &cursor
+	}
+
+	if params.Before != nil && params.After != nil {
+		respondCode(CodeBadRequest, "Only one of before and after can be set.", w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "read messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	page, err := chatroom.ListMessages(params, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(fmt.Errorf("Err reading messages: %v", err), w, r)
+		return
+	}
+
+	room, err := s.db.Queries().FindChatRoomById(r.Context(), roomID)
+	if err == nil && room.Type == chatroom.TypeDirect {
+		err = chatroom.AttachReadReceipts(page.Messages, roomID, r.Context(), s.db.Queries)
+	}
+	if err != nil {
+		respondError(fmt.Errorf("Err reading read receipts: %v", err), w, r)
+		return
+	}
+
+	respondWithJson(page, 200, w)
+}
+
+func (s *Server) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "send attachments", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	// Leave some room for the other form fields.
+	r.Body = http.MaxBytesReader(w, r.Body, chatroom.MaxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(chatroom.ErrAttachmentTooLarge, w, r)
+			return
+		}
+		respondCode(CodeBadRequest, "Attachments must be sent as multipart/form-data.", w, r)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(invalidField("file", "file is required."), w, r)
+		return
+	}
+	defer file.Close()
+
+	var replyToID uuid.NullUUID
+	if value := r.FormValue("reply_to_id"); value != "" {
+		replyToID.UUID, err = uuid.Parse(value)
+		if err != nil {
+			respondError(invalidField("reply_to_id", "reply_to_id must be a valid ID."), w, r)
+			return
+		}
+		replyToID.Valid = true
+	}
+
+	params := chatroom.SendAttachmentParams{
+		AuthorID:   currentUserId,
+		ChatroomID: roomID,
+		Caption:    r.FormValue("content"),
+		ReplyToID:  replyToID,
+		Name:       header.Filename,
+		Size:       header.Size,
+		Body:       file,
+	}
+
+	var msg chatroom.Message
+	upload, err := chatroom.StoreAttachment(params, s.storage, r.Context())
+	if err == nil {
+		err = s.db.WithTx(r.Context(), func(dbq func() *db.Queries) error {
+			var err error
+			msg, err = chatroom.SendAttachment(params, upload, r.Context(), dbq)
+			return err
+		})
+		if err != nil {
+			chatroom.DiscardUpload(upload, s.storage)
+		}
+	}
+
+	switch {
+	case errors.Is(err, chatroom.ErrMessageNotFound):
+		respondError(errReplyNotFound, w, r)
+		return
+	case err != nil:
+		respondError(err, w, r)
+		return
+	}
+
+	if msg.Type == chatroom.MessageTypeImage {
+		s.thumbnails.Enqueue(msg.Attachment.ID)
+	}
+	s.presence.StopTyping(currentUserId, roomID)
+
+	s.publish(r.Context(), realtime.Event{
+		Type:       realtime.EventMessageCreated,
+		ID:         msg.ID,
+		ChatroomID: roomID,
+		Data:       msg,
+	})
+
+	respondWithJson(msg, 201, w)
+}
+
+func (s *Server) DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveAttachment(chatroom.OpenAttachment, w, r)
+}
+
+func (s *Server) DownloadThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveAttachment(chatroom.OpenThumbnail, w, r)
+}
+
+type openAttachmentFunc func(attachmentId, chatroomId uuid.UUID, store storage.Store, ctx context.Context, dbq func() *db.Queries) (chatroom.Download, error)
+
+func (s *Server) serveAttachment(open openAttachmentFunc, w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	attachmentID, err := parseID(r, "attachmentID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(currentUserId, roomID, "download attachments", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	download, err := open(attachmentID, roomID, s.storage, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+	defer download.Body.Close()
+
+	// Only images known to be safe are shown inline.
+	disposition := "attachment"
+	if chatroom.IsImageMimeType(download.MimeType) {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", download.MimeType)
+	if download.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(download.Size, 10))
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": download.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(200)
+
+	if _, err := io.Copy(w, download.Body); err != nil {
+		log.Printf("Err sending attachment: %v", err)
+	}
+}
+
+func (s *Server) SearchMessagesHandler(w http.ResponseWriter, r *http.Request) {
+
+	currentUserId := auth.MustUserID(r.Context())
+
+	query := r.URL.Query()
+	params := chatroom.SearchMessagesParams{
+		UserID: currentUserId,
+		Query:  query.Get("q"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		params.Limit, err = strconv.Atoi(limit)
+		if err != nil || params.Limit < 1 {
+			respondError(invalidParam("limit", "limit must be a positive number."), w, r)
+			return
+		}
+	}
+
+	if before := query.Get("before"); before != "" {
+		cursor, err := chatroom.DecodeCursor(before)
+		if err != nil {
+			respondError(invalidParam("before", err.Error()), w, r)
+			return
+		}
+		params.Before = &cursor
+	}
+
+	page, err := chatroom.SearchMessages(params, r.Context(), s.db.Queries)
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	respondWithJson(page, 200, w)
+}
+
+func (s *Server) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID := auth.MustUserID(r.Context())
+
+	rooms, err := s.db.Queries().FindUsersChatrooms(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondError(fmt.Errorf("Err Geting users rooms: %v", err), w, r)
+		return
+	}
+
+	roomIDs := make([]uuid.UUID, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied to the client
+		log.Printf("Err upgrading to websocket: %v", err)
+		return
+	}
+
+	client, err := s.hub.Register(userID, roomIDs)
+	if err != nil {
+		log.Printf("Err registering websocket client: %v", err)
+		conn.Close()
+		return
+	}
+
+	s.presence.Connect(userID)
+	defer s.presence.Disconnect(userID)
+
+	realtime.ServeWebsocket(s.hub, client, conn, s.handleClientMessage)
+}
+
+func (s *Server) handleClientMessage(c *realtime.Client, msg realtime.ClientMessage) {
+
+	// Clients only ever listen to the chatrooms they participate in.
+	if !s.hub.Subscribed(c, msg.ChatroomID) {
+		return
+	}
+
+	switch msg.Type {
+	case realtime.ClientTyping:
+		s.presence.SetTyping(c.UserID, msg.ChatroomID)
+	case realtime.ClientTypingStopped:
+		s.presence.StopTyping(c.UserID, msg.ChatroomID)
+	}
+}
+
+// ChatroomEventsHandler streams the chatroom's events as Server-Sent Events,
+// for clients whose proxies do not let websockets through.
+func (s *Server) ChatroomEventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID := auth.MustUserID(r.Context())
+
+	roomID, err := parseID(r, "chatroomID")
+	if err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	if err := s.requireParticipant(userID, roomID, "read messages", r.Context()); err != nil {
+		respondError(err, w, r)
+		return
+	}
+
+	// Subscribe before loading the backlog, so nothing sent in between is missed.
+	client, err := s.hub.RegisterRoom(userID, roomID)
+	if err != nil {
+		log.Printf("Err registering event stream client: %v", err)
+		respondCode(CodeUnavailable, "Service unavailable.", w, r)
+		return
+	}
+
+	var backlog []realtime.Event
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		backlog, err = s.missedEvents(roomID, lastEventID, r.Context())
+		if err != nil {
+			s.hub.Unregister(client)
+			respondError(err, w, r)
+			return
+		}
+	}
+
+	s.presence.Connect(userID)
+	defer s.presence.Disconnect(userID)
+
+	if err := realtime.ServeSSE(s.hub, client, w, r, backlog); err != nil {
+		log.Printf("Event stream closed with error: %v", err)
+	}
+}
+
+// missedEvents returns the chatroom's events after the one the client saw
+// last. Clients that missed too much, or send an ID from before events were
+// numbered, get a single resync event instead.
+func (s *Server) missedEvents(roomID uuid.UUID, lastEventID string, ctx context.Context) ([]realtime.Event, error) {
+
+	var events []db.ChatroomEvent
+	err := chatroom.ErrEventsGone
+	if seq, parseErr := strconv.ParseInt(lastEventID, 10, 64); parseErr == nil {
+		events, err = chatroom.FindMissedEvents(roomID, seq, ctx, s.db.Queries)
+	}
+
+	if errors.Is(err, chatroom.ErrEventsGone) {
+		latest, err := chatroom.LatestEventSeq(roomID, ctx, s.db.Queries)
+		if err != nil {
+			return nil, err
+		}
+		// The stream picks up after the latest event, so the client does
+		// not resync again when it reconnects.
+		return []realtime.Event{{
+			Type:       realtime.EventChatroomResync,
+			Seq:        latest,
+			ID:         roomID,
+			ChatroomID: roomID,
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backlog := make([]realtime.Event, 0, len(events))
+	for _, e := range events {
+		backlog = append(backlog, realtime.Event{
+			Type:       e.Type,
+			Seq:        e.Seq,
+			ID:         e.ResourceID,
+			ChatroomID: e.ChatroomID,
+			Data:       e.Data,
+		})
+	}
+
+	return backlog, nil
+}
+
+// findBodyUser looks up the user whose ID was sent in the named request field.
+func (s *Server) findBodyUser(field, value string, ctx context.Context) (user.User, error) {
+
+	id, err := parseBodyID(field, value)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	u, err := user.GetUserById(id.String(), s.presence, ctx, s.db.Queries)
+	if errors.Is(err, user.ErrUserNotFound) {
+		return user.User{}, APIError{
+			Code:    CodeUserNotFound,
+			Message: err.Error(),
+			Fields:  []FieldError{{Field: field, Message: err.Error()}},
+		}
+	}
+
+	return u, err
+}
+
+// requireParticipant fails unless the user participates in the chatroom;
+// action says what for.
+func (s *Server) requireParticipant(userId, roomId uuid.UUID, action string, ctx context.Context) error {
+
+	isParticipant, err := chatroom.IsUserParticipantInChatroom(userId, roomId, ctx, s.db.Queries)
+	if err != nil {
+		return findChatroomError(err)
+	}
+
+	if !isParticipant {
+		return APIError{
+			Code:    CodeNotParticipant,
+			Message: fmt.Sprintf("User must participate in the chatroom to %s.", action),
+		}
+	}
+
+	return nil
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -1,10 +1,18 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/fernandofreamunde/ika/internal/storage"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +37,105 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestChatroomEventsReplay(t *testing.T) {
+	s, fx := newFakeServer(t)
+	handler := s.RegisterRoutes()
+
+	token, err := auth.MakeJWT(fx.me, "topSecret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+
+	stream := func(lastEventID string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		r := httptest.NewRequest("GET", "/api/chatrooms/"+fx.room.String()+"/events", nil).WithContext(ctx)
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Last-Event-ID", lastEventID)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Body.String()
+	}
+
+	// Every kind of event is replayed, not only new messages.
+	if body := stream("1"); !strings.Contains(body, "id: 2\nevent: message.updated\n") {
+		t.Errorf("expected the missed edit to be replayed:\n%s", body)
+	}
+
+	// IDs from before events were numbered can not be resumed from.
+	if body := stream(fx.message.String()); !strings.Contains(body, "id: 2\nevent: chatroom.resync\n") {
+		t.Errorf("expected a resync event:\n%s", body)
+	}
+}
+
+func TestDeleteChatroomDeletesBlobs(t *testing.T) {
+	s, fx := newFakeServer(t)
+	s.db.(*dbtest.DB).Results["DeleteAttachmentsByChatroomId"] = dbtest.One(fx.attachmentKey, fx.thumbnailKey)
+	handler := s.RegisterRoutes()
+
+	token, err := auth.MakeJWT(fx.me, "topSecret", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to make JWT: %v", err)
+	}
+
+	r := httptest.NewRequest("DELETE", "/api/chatrooms/"+fx.room.String()+"?for=everyone", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204; got %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, key := range []string{fx.attachmentKey, fx.thumbnailKey} {
+		if _, err := s.storage.Get(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected %s to be deleted; got %v", key, err)
+		}
+	}
+}
+
+func TestGetUserVisibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		discoverable bool
+		results      map[string]dbtest.Result
+		status       int
+	}{
+		{"discoverable", true, nil, http.StatusOK},
+		{"blocked", true, map[string]dbtest.Result{"IsBlockedBetween": dbtest.One(true)}, http.StatusNotFound},
+		{"hidden stranger", false, map[string]dbtest.Result{"AreContacts": dbtest.One(false), "ShareChatroom": dbtest.One(false)}, http.StatusNotFound},
+		{"hidden contact", false, map[string]dbtest.Result{"ShareChatroom": dbtest.One(false)}, http.StatusOK},
+		{"hidden chatroom participant", false, map[string]dbtest.Result{"AreContacts": dbtest.One(false)}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fx := newFakeServer(t)
+			results := s.db.(*dbtest.DB).Results
+			results["FindUserById"] = dbtest.One(fx.stranger.String(), time.Now(), time.Now(), "", "kani", "kani@example.com", false, tt.discoverable)
+			for name, result := range tt.results {
+				results[name] = result
+			}
+
+			token, err := auth.MakeJWT(fx.me, "topSecret", time.Minute)
+			if err != nil {
+				t.Fatalf("Failed to make JWT: %v", err)
+			}
+
+			r := httptest.NewRequest("GET", "/api/users/"+fx.stranger.String(), nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			s.RegisterRoutes().ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d; got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if strings.Contains(rec.Body.String(), "@example.com") {
+				t.Errorf("expected no email in %s", rec.Body)
+			}
+		})
+	}
+}
@@ -1,32 +1,50 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/joho/godotenv/autoload"
 
+	"github.com/fernandofreamunde/ika/internal/auth"
+	"github.com/fernandofreamunde/ika/internal/chatroom"
+	"github.com/fernandofreamunde/ika/internal/config"
 	"github.com/fernandofreamunde/ika/internal/database"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/fernandofreamunde/ika/internal/realtime"
+	"github.com/fernandofreamunde/ika/internal/storage"
+	"github.com/fernandofreamunde/ika/internal/thumbnail"
 )
 
 type Server struct {
-	port int
-	currentUserId uuid.UUID
+	port   int
+	tokens *auth.TokenIssuer
 
-	db database.Service
+	db         database.Service
+	hub        *realtime.Hub
+	storage    storage.Store
+	thumbnails *thumbnail.Worker
+	presence   *presence.Tracker
 }
 
-func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
+func NewServer(cfg config.Config, db database.Service) *http.Server {
 	NewServer := &Server{
-		port: port,
+		port:   cfg.Port,
+		tokens: auth.NewTokenIssuer(cfg.AppSecret),
 
-		db: database.New(),
+		db:      db,
+		hub:     realtime.NewHub(),
+		storage: storage.New(cfg.Storage),
 	}
+	NewServer.thumbnails = thumbnail.NewWorker(NewServer.db.Queries, NewServer.storage)
+	NewServer.thumbnails.Start(2)
+	NewServer.presence = presence.NewTracker(NewServer.publishTyping)
+
+	stopPruning := make(chan struct{})
+	go NewServer.pruneEvents(stopPruning)
 
 	// Declare Server config
 	server := &http.Server{
@@ -37,5 +55,60 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Hijacked websocket connections are not tracked by Shutdown, so the hub
+	// has to be told to disconnect its clients.
+	server.RegisterOnShutdown(NewServer.hub.Close)
+	server.RegisterOnShutdown(NewServer.thumbnails.Close)
+	server.RegisterOnShutdown(func() { close(stopPruning) })
+
 	return server
 }
+
+// publishTyping lets the other participants of the chatroom know someone
+// started or stopped typing.
+func (s *Server) publishTyping(userID, roomID uuid.UUID, typing bool) {
+	eventType := realtime.EventTypingStopped
+	if typing {
+		eventType = realtime.EventTypingStarted
+	}
+
+	s.hub.Publish(realtime.Event{
+		Type:       eventType,
+		ID:         userID,
+		ChatroomID: roomID,
+		Data:       map[string]uuid.UUID{"user_id": userID},
+	})
+}
+
+// publish records the event in the chatroom's event log, so event streams can
+// replay it, and pushes it to the connected clients.
+func (s *Server) publish(ctx context.Context, e realtime.Event) {
+	// The change is already made, so the event is recorded even if the
+	// client went away meanwhile.
+	seq, err := chatroom.RecordEvent(e.ChatroomID, e.Type, e.ID, e.Data, context.WithoutCancel(ctx), s.db.Queries)
+	if err != nil {
+		log.Printf("Err recording %s event: %v", e.Type, err)
+	}
+	e.Seq = seq
+
+	s.hub.Publish(e)
+}
+
+// pruneEvents drops the events older than chatroom.EventRetention every hour,
+// until stop is closed.
+func (s *Server) pruneEvents(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			before := time.Now().Add(-chatroom.EventRetention)
+			if _, err := chatroom.PruneEvents(before, context.Background(), s.db.Queries); err != nil {
+				log.Printf("%v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
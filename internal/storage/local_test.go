@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	key := "attachments/room/some file.txt"
+
+	if err := store.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, _ := io.ReadAll(r)
+	r.Close()
+	if string(body) != "hello" {
+		t.Errorf("Get returned %q, want %q", body, "hello")
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Deleting a missing blob: %v", err)
+	}
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	testStore(t, NewLocalStore(t.TempDir()))
+}
+
+func TestLocalStoreRejectsKeysOutsideRoot(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+
+	err := store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, "")
+	if err == nil {
+		t.Fatal("expected an error for a key outside of the root")
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/fernandofreamunde/ika/internal/config"
+)
+
+var ErrNotFound = errors.New("Blob not found.")
+
+// Store keeps blobs, like attachments, outside of the database. Keys are
+// slash separated paths chosen by the caller.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any previous blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get returns the blob stored under key, or ErrNotFound.
+	// The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the store selected by the configured driver, "local" or "s3".
+func New(cfg config.Storage) Store {
+	switch cfg.Driver {
+	case "local":
+		return NewLocalStore(cfg.Path)
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return store
+	default:
+		log.Fatalf("Unknown storage driver %q", cfg.Driver)
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	// Decoders for the image types accepted as image messages.
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Thumbnails fit in a Size x Size box.
+	Size = 320
+
+	// Limits on images accepted as image messages, decoding is done in
+	// memory so they also bound what a thumbnail costs.
+	MaxDimension = 10000
+	MaxPixels    = 40_000_000
+)
+
+const (
+	StatusNone    = "none"
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+var (
+	ErrInvalidImage  = errors.New("Image could not be decoded.")
+	ErrImageTooLarge = errors.New("Image dimensions are too large.")
+)
+
+// Check reads the image header and verifies its dimensions are within the
+// limits, without decoding the whole image.
+func Check(r io.Reader) (image.Config, error) {
+
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return image.Config{}, ErrInvalidImage
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return image.Config{}, ErrInvalidImage
+	}
+
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return image.Config{}, ErrImageTooLarge
+	}
+
+	return config, nil
+}
+
+// Fit returns the dimensions of a width x height image scaled down to fit in
+// a size x size box. Images already fitting are left as they are.
+func Fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// Generate decodes an image and returns a JPEG thumbnail of it along with the
+// thumbnail's dimensions. Transparent areas end up white.
+func Generate(r io.Reader, size int) ([]byte, image.Point, error) {
+
+	// Check consumes the header, so keep it around for the real decode.
+	var header bytes.Buffer
+	if _, err := Check(io.TeeReader(r, &header)); err != nil {
+		return nil, image.Point{}, err
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, image.Point{}, ErrInvalidImage
+	}
+
+	bounds := src.Bounds()
+	width, height := Fit(bounds.Dx(), bounds.Dy(), size)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, image.Point{}, fmt.Errorf("Err encoding thumbnail: %v", err)
+	}
+
+	return out.Bytes(), image.Pt(width, height), nil
+}
@@ -0,0 +1,87 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantW, wantH  int
+	}{
+		{100, 50, 100, 50},
+		{640, 480, 320, 240},
+		{480, 640, 240, 320},
+		{1000, 1000, 320, 320},
+		{10000, 1, 320, 1},
+	}
+
+	for _, tt := range tests {
+		w, h := Fit(tt.width, tt.height, 320)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("Fit(%d, %d) = %d, %d, want %d, %d", tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	config, err := Check(bytes.NewReader(encodePNG(t, 40, 30)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 40 || config.Height != 30 {
+		t.Errorf("Check() = %dx%d, want 40x30", config.Width, config.Height)
+	}
+
+	if _, err := Check(bytes.NewReader([]byte("\x89PNG\r\n\x1a\nnot really"))); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Check(broken png) = %v, want ErrInvalidImage", err)
+	}
+
+	// Only the header is read, so the pixels need not be there.
+	huge := []byte("GIF89a\x20\x4e\x01\x00\x00\x00\x00") // 20000x1
+	if _, err := Check(bytes.NewReader(huge)); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Check(20000px wide gif) = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	thumb, size, err := Generate(bytes.NewReader(encodePNG(t, 800, 400)), Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != image.Pt(320, 160) {
+		t.Errorf("Generate() size = %v, want (320,160)", size)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if img.Bounds().Size() != size {
+		t.Errorf("thumbnail is %v, want %v", img.Bounds().Size(), size)
+	}
+}
@@ -0,0 +1,147 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/storage"
+	"github.com/google/uuid"
+)
+
+// queueSize is how many attachments may wait for a thumbnail. Attachments
+// that do not fit stay pending and are picked up on the next start.
+const queueSize = 256
+
+// How long generating a single thumbnail may take.
+const jobTimeout = time.Minute
+
+// Worker generates thumbnails of image attachments in the background.
+type Worker struct {
+	dbq   func() *db.Queries
+	store storage.Store
+
+	jobs      chan uuid.UUID
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewWorker(dbq func() *db.Queries, store storage.Store) *Worker {
+	return &Worker{
+		dbq:   dbq,
+		store: store,
+		jobs:  make(chan uuid.UUID, queueSize),
+		quit:  make(chan struct{}),
+	}
+}
+
+// Start runs n workers and queues the attachments left pending by a
+// previous run.
+func (w *Worker) Start(n int) {
+	for i := 0; i < n; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+		defer cancel()
+
+		ids, err := w.dbq().FindPendingThumbnailIds(ctx)
+		if err != nil {
+			log.Printf("Err finding pending thumbnails: %v", err)
+			return
+		}
+		for _, id := range ids {
+			w.Enqueue(id)
+		}
+	}()
+}
+
+// Enqueue asks for a thumbnail of the attachment, it never blocks.
+func (w *Worker) Enqueue(attachmentId uuid.UUID) {
+	select {
+	case <-w.quit:
+	case w.jobs <- attachmentId:
+	default:
+		log.Printf("Thumbnail queue is full, attachment %s stays pending", attachmentId)
+	}
+}
+
+// Close stops the workers once they finish the thumbnail they are on.
+func (w *Worker) Close() {
+	w.closeOnce.Do(func() {
+		close(w.quit)
+	})
+	w.wg.Wait()
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.quit:
+			return
+		case id := <-w.jobs:
+			ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+			if err := w.process(ctx, id); err != nil {
+				log.Printf("Err generating thumbnail of %s: %v", id, err)
+				if err := w.dbq().SetAttachmentThumbnailStatus(ctx, db.SetAttachmentThumbnailStatusParams{
+					ID:              id,
+					ThumbnailStatus: StatusFailed,
+				}); err != nil {
+					log.Printf("Err updating thumbnail status of %s: %v", id, err)
+				}
+			}
+			cancel()
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, id uuid.UUID) error {
+
+	attachment, err := w.dbq().FindAttachmentById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("Err finding attachment: %v", err)
+	}
+
+	// It may have been queued twice, or deleted meanwhile.
+	if attachment.ThumbnailStatus != StatusPending {
+		return nil
+	}
+
+	body, err := w.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	thumb, size, err := Generate(body, Size)
+	if err != nil {
+		return err
+	}
+
+	key := attachment.StorageKey + "-thumbnail"
+	if err := w.store.Put(ctx, key, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+		return err
+	}
+
+	err = w.dbq().SetAttachmentThumbnail(ctx, db.SetAttachmentThumbnailParams{
+		ID:              id,
+		ThumbnailKey:    sql.NullString{String: key, Valid: true},
+		ThumbnailWidth:  sql.NullInt32{Int32: int32(size.X), Valid: true},
+		ThumbnailHeight: sql.NullInt32{Int32: int32(size.Y), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("Err saving thumbnail: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBlockSelf  = errors.New("Users can not block themselves.")
+	ErrNotBlocked = errors.New("User is not blocked.")
+	// ErrBlocked is returned when one user tries to reach another across a block.
+	ErrBlocked = errors.New("User is not accepting messages from you.")
+)
+
+type BlockedUser struct {
+	ID        uuid.UUID `json:"id"`
+	Nickname  string    `json:"nickname"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockUser stops the blocked user from reaching the blocker and drops any
+// contact or friend request between them. Blocking a user twice is fine.
+func BlockUser(blockerId, blockedId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	if blockerId == blockedId {
+		return ErrBlockSelf
+	}
+
+	err := dbq().BlockUser(ctx, db.BlockUserParams{
+		BlockerID: blockerId,
+		BlockedID: blockedId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err blocking user: %v", err)
+	}
+
+	if err := RemoveContact(blockerId, blockedId, ctx, dbq); err != nil && !errors.Is(err, ErrNotContacts) {
+		return err
+	}
+	for _, pair := range [][2]uuid.UUID{{blockerId, blockedId}, {blockedId, blockerId}} {
+		if err := deleteFriendRequest(pair[0], pair[1], ctx, dbq); err != nil && !errors.Is(err, ErrRequestNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func UnblockUser(blockerId, blockedId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	n, err := dbq().UnblockUser(ctx, db.UnblockUserParams{
+		BlockerID: blockerId,
+		BlockedID: blockedId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err unblocking user: %v", err)
+	}
+
+	if n == 0 {
+		return ErrNotBlocked
+	}
+
+	return nil
+}
+
+// FindBlockedUsers returns who the user blocked, most recent first.
+func FindBlockedUsers(blockerId uuid.UUID, ctx context.Context, dbq func() *db.Queries) ([]BlockedUser, error) {
+
+	rows, err := dbq().FindBlockedUsers(ctx, blockerId)
+	if err != nil {
+		return nil, fmt.Errorf("Err finding blocked users: %v", err)
+	}
+
+	blocked := make([]BlockedUser, 0, len(rows))
+	for _, row := range rows {
+		blocked = append(blocked, BlockedUser{
+			ID:        row.ID,
+			Nickname:  row.Nickname,
+			BlockedAt: row.BlockedAt,
+		})
+	}
+
+	return blocked, nil
+}
+
+// HasBlocked tells if the blocker blocked the other user.
+func HasBlocked(blockerId, blockedId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (bool, error) {
+
+	blocked, err := dbq().HasBlocked(ctx, db.HasBlockedParams{
+		BlockerID: blockerId,
+		BlockedID: blockedId,
+	})
+	if err != nil {
+		return false, fmt.Errorf("Err checking blocks: %v", err)
+	}
+
+	return blocked, nil
+}
+
+// IsBlockedBetween tells if either user blocked the other.
+func IsBlockedBetween(a, b uuid.UUID, ctx context.Context, dbq func() *db.Queries) (bool, error) {
+
+	blocked, err := dbq().IsBlockedBetween(ctx, db.IsBlockedBetweenParams{
+		BlockerID: a,
+		BlockedID: b,
+	})
+	if err != nil {
+		return false, fmt.Errorf("Err checking blocks: %v", err)
+	}
+
+	return blocked, nil
+}
@@ -0,0 +1,41 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+func TestBlockUserRejectsSelf(t *testing.T) {
+	id := uuid.New()
+
+	// The check happens before touching the database.
+	err := BlockUser(id, id, context.Background(), nil)
+	if !errors.Is(err, ErrBlockSelf) {
+		t.Fatalf("BlockUser(self) = %v, want ErrBlockSelf", err)
+	}
+}
+
+func TestBlockUserRollsBackMidway(t *testing.T) {
+	f := dbtest.New(nil)
+	f.FailOn("DeleteFriendRequest", 2)
+	defer f.Close()
+
+	err := f.WithTx(context.Background(), func(dbq func() *db.Queries) error {
+		return BlockUser(uuid.New(), uuid.New(), context.Background(), dbq)
+	})
+
+	if err == nil {
+		t.Fatalf("WithTx = nil, want the injected failure")
+	}
+	if f.Calls("BlockUser") != 1 {
+		t.Errorf("BlockUser ran %d times, want once", f.Calls("BlockUser"))
+	}
+	if f.Commits() != 0 || f.Rollbacks() != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want 0 and 1", f.Commits(), f.Rollbacks())
+	}
+}
@@ -0,0 +1,244 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/google/uuid"
+)
+
+// How a contact looks from the user listing their contacts.
+const (
+	ContactAccepted = "accepted"
+	// ContactIncoming is a friend request the user received.
+	ContactIncoming = "incoming"
+	// ContactOutgoing is a friend request the user sent.
+	ContactOutgoing = "outgoing"
+)
+
+// Statuses as stored in the contacts table.
+const (
+	contactPending  = "pending"
+	contactAccepted = "accepted"
+)
+
+var (
+	ErrFriendSelf      = errors.New("Users can not befriend themselves.")
+	ErrAlreadyContacts = errors.New("Users are already contacts.")
+	ErrRequestExists   = errors.New("Friend request already sent.")
+	ErrRequestNotFound = errors.New("Friend request not found.")
+	ErrNotContacts     = errors.New("Users are not contacts.")
+	ErrContactsOnly    = errors.New("User only accepts direct chatrooms from contacts.")
+)
+
+type Contact struct {
+	User   User      `json:"user"`
+	Status string    `json:"status"`
+	Since  time.Time `json:"since"`
+}
+
+// SendFriendRequest asks the addressee to become a contact of the requester.
+// If the addressee already asked the requester, they become contacts right
+// away. It returns the resulting status as seen by the requester.
+func SendFriendRequest(requesterId, addresseeId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (string, error) {
+
+	if requesterId == addresseeId {
+		return "", ErrFriendSelf
+	}
+
+	blocked, err := IsBlockedBetween(requesterId, addresseeId, ctx, dbq)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrBlocked
+	}
+
+	status, err := answerExistingContact(requesterId, addresseeId, ctx, dbq)
+	if status != "" || err != nil {
+		return status, err
+	}
+
+	_, err = dbq().CreateFriendRequest(ctx, db.CreateFriendRequestParams{
+		RequesterID: requesterId,
+		AddresseeID: addresseeId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// A request between the two was made meanwhile, likely the addressee
+		// asking the requester at the same moment.
+		status, err := answerExistingContact(requesterId, addresseeId, ctx, dbq)
+		if status == "" && err == nil {
+			return "", ErrRequestExists
+		}
+		return status, err
+	}
+	if err != nil {
+		return "", fmt.Errorf("Err creating friend request: %v", err)
+	}
+
+	return ContactOutgoing, nil
+}
+
+// answerExistingContact settles a friend request against the request or
+// contact already between the two users. It returns no status when there is
+// none.
+func answerExistingContact(requesterId, addresseeId uuid.UUID, ctx context.Context, dbq func() *db.Queries) (string, error) {
+
+	existing, err := dbq().FindContactBetween(ctx, db.FindContactBetweenParams{
+		RequesterID: requesterId,
+		AddresseeID: addresseeId,
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("Err finding contact: %v", err)
+	case existing.Status == contactAccepted:
+		return "", ErrAlreadyContacts
+	case existing.RequesterID == requesterId:
+		return "", ErrRequestExists
+	}
+
+	if err := AcceptFriendRequest(requesterId, addresseeId, ctx, dbq); err != nil {
+		return "", err
+	}
+	return ContactAccepted, nil
+}
+
+// AcceptFriendRequest accepts the request the requester sent to the addressee.
+func AcceptFriendRequest(addresseeId, requesterId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	_, err := dbq().AcceptFriendRequest(ctx, db.AcceptFriendRequestParams{
+		RequesterID: requesterId,
+		AddresseeID: addresseeId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRequestNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Err accepting friend request: %v", err)
+	}
+
+	return nil
+}
+
+// DeclineFriendRequest drops the request the requester sent to the addressee.
+func DeclineFriendRequest(addresseeId, requesterId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+	return deleteFriendRequest(requesterId, addresseeId, ctx, dbq)
+}
+
+// CancelFriendRequest withdraws the request the requester sent to the addressee.
+func CancelFriendRequest(requesterId, addresseeId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+	return deleteFriendRequest(requesterId, addresseeId, ctx, dbq)
+}
+
+func deleteFriendRequest(requesterId, addresseeId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	n, err := dbq().DeleteFriendRequest(ctx, db.DeleteFriendRequestParams{
+		RequesterID: requesterId,
+		AddresseeID: addresseeId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err deleting friend request: %v", err)
+	}
+
+	if n == 0 {
+		return ErrRequestNotFound
+	}
+
+	return nil
+}
+
+func RemoveContact(userId, contactId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	n, err := dbq().DeleteContactBetween(ctx, db.DeleteContactBetweenParams{
+		RequesterID: userId,
+		AddresseeID: contactId,
+	})
+	if err != nil {
+		return fmt.Errorf("Err removing contact: %v", err)
+	}
+
+	if n == 0 {
+		return ErrNotContacts
+	}
+
+	return nil
+}
+
+// FindContacts returns the user's contacts and pending friend requests, most
+// recently changed first.
+func FindContacts(userId uuid.UUID, tracker *presence.Tracker, ctx context.Context, dbq func() *db.Queries) ([]Contact, error) {
+
+	rows, err := dbq().FindContactsByUserId(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Err finding contacts: %v", err)
+	}
+
+	contacts := make([]Contact, 0, len(rows))
+	for _, row := range rows {
+		status := ContactAccepted
+		if row.Status == contactPending && row.RequesterID == userId {
+			status = ContactOutgoing
+		} else if row.Status == contactPending {
+			status = ContactIncoming
+		}
+
+		p := tracker.Lookup(row.ID)
+		contacts = append(contacts, Contact{
+			User: User{
+				ID:        row.ID,
+				Nickname:  row.Nickname,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Presence:  &p,
+			},
+			Status: status,
+			Since:  row.Since,
+		})
+	}
+
+	return contacts, nil
+}
+
+func AreContacts(a, b uuid.UUID, ctx context.Context, dbq func() *db.Queries) (bool, error) {
+
+	contacts, err := dbq().AreContacts(ctx, db.AreContactsParams{
+		RequesterID: a,
+		AddresseeID: b,
+	})
+	if err != nil {
+		return false, fmt.Errorf("Err checking contacts: %v", err)
+	}
+
+	return contacts, nil
+}
+
+// CanOpenDirectChatroom tells if the requester may open a direct chatroom
+// with the addressee, who may only accept them from contacts.
+func CanOpenDirectChatroom(requesterId, addresseeId uuid.UUID, ctx context.Context, dbq func() *db.Queries) error {
+
+	addressee, err := dbq().FindUserById(ctx, addresseeId)
+	if err != nil {
+		return fmt.Errorf("Err finding user: %v", err)
+	}
+
+	if !addressee.ContactsOnly {
+		return nil
+	}
+
+	contacts, err := AreContacts(requesterId, addresseeId, ctx, dbq)
+	if err != nil {
+		return err
+	}
+	if !contacts {
+		return ErrContactsOnly
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fernandofreamunde/ika/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+func TestSendFriendRequestRejectsSelf(t *testing.T) {
+	id := uuid.New()
+
+	// The check happens before touching the database.
+	_, err := SendFriendRequest(id, id, context.Background(), nil)
+	if !errors.Is(err, ErrFriendSelf) {
+		t.Fatalf("SendFriendRequest(self) = %v, want ErrFriendSelf", err)
+	}
+}
+
+// Two users asking each other at the same moment both miss the other's
+// request, the one whose insert loses accepts the other's instead.
+func TestSendFriendRequestRace(t *testing.T) {
+	requester, addressee := uuid.New(), uuid.New()
+	now := time.Now()
+
+	found := 0
+	f := dbtest.New(map[string]dbtest.Result{
+		"IsBlockedBetween": dbtest.One(false),
+		"FindContactBetween": func(args []driver.Value) ([]string, [][]driver.Value) {
+			found++
+			if found == 1 {
+				return nil, nil
+			}
+			return dbtest.Rows([]driver.Value{addressee.String(), requester.String(), contactPending, now, now})
+		},
+		"AcceptFriendRequest": dbtest.One(addressee.String(), requester.String(), contactAccepted, now, now),
+	})
+	defer f.Close()
+
+	status, err := SendFriendRequest(requester, addressee, context.Background(), f.Queries)
+	if err != nil {
+		t.Fatalf("SendFriendRequest = %v", err)
+	}
+	if status != ContactAccepted {
+		t.Errorf("status = %q, want %q", status, ContactAccepted)
+	}
+	if f.Calls("CreateFriendRequest") != 1 || f.Calls("AcceptFriendRequest") != 1 {
+		t.Errorf("expected the insert to lose and the other request to be accepted")
+	}
+}
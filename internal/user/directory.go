@@ -0,0 +1,137 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/presence"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultDirectoryPageSize = 20
+	MaxDirectoryPageSize     = 50
+)
+
+var ErrEmptyUserSearch = errors.New("Search query can not be empty.")
+
+// DirectoryCursor points at a user by their position in the directory's
+// (lower(nickname), id) ordering.
+type DirectoryCursor struct {
+	Nickname string
+	ID       uuid.UUID
+}
+
+func (c DirectoryCursor) Encode() string {
+	raw := fmt.Sprintf("%s|%s", c.Nickname, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeDirectoryCursor(s string) (DirectoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return DirectoryCursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	// Nicknames may contain the separator, ids never do.
+	i := strings.LastIndex(string(raw), "|")
+	if i < 0 {
+		return DirectoryCursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	id, err := uuid.Parse(string(raw[i+1:]))
+	if err != nil {
+		return DirectoryCursor{}, fmt.Errorf("Invalid cursor.")
+	}
+
+	return DirectoryCursor{Nickname: string(raw[:i]), ID: id}, nil
+}
+
+type SearchUsersParams struct {
+	// UserID is the user searching, who never finds themselves nor users
+	// they blocked or were blocked by.
+	UserID uuid.UUID
+	Query  string
+	After  *DirectoryCursor
+	Limit  int
+}
+
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// nicknamePrefixPattern turns a nickname prefix into a LIKE pattern matching
+// lower(nickname).
+func nicknamePrefixPattern(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(strings.ToLower(prefix)) + "%"
+}
+
+// SearchUsers finds discoverable users whose nickname starts with the query,
+// in alphabetical order.
+func SearchUsers(params SearchUsersParams, tracker *presence.Tracker, ctx context.Context, dbq func() *db.Queries) (UserPage, error) {
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return UserPage{}, ErrEmptyUserSearch
+	}
+
+	if params.Limit <= 0 {
+		params.Limit = DefaultDirectoryPageSize
+	}
+	if params.Limit > MaxDirectoryPageSize {
+		params.Limit = MaxDirectoryPageSize
+	}
+
+	// Fetch one extra row to know if there is another page.
+	limit := int32(params.Limit + 1)
+	pattern := nicknamePrefixPattern(params.Query)
+
+	var rows []db.User
+	var err error
+	if params.After != nil {
+		rows, err = dbq().SearchUsersAfter(ctx, db.SearchUsersAfterParams{
+			ID:      params.UserID,
+			Lower:   pattern,
+			Lower_2: params.After.Nickname,
+			ID_2:    params.After.ID,
+			Limit:   limit,
+		})
+	} else {
+		rows, err = dbq().SearchUsers(ctx, db.SearchUsersParams{
+			ID:    params.UserID,
+			Lower: pattern,
+			Limit: limit,
+		})
+	}
+
+	if err != nil {
+		return UserPage{}, fmt.Errorf("Err searching users: %v", err)
+	}
+
+	page := UserPage{}
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		last := rows[params.Limit-1]
+		page.NextCursor = DirectoryCursor{Nickname: strings.ToLower(last.Nickname), ID: last.ID}.Encode()
+	}
+
+	page.Users = make([]User, 0, len(rows))
+	for _, row := range rows {
+		p := tracker.Lookup(row.ID)
+		page.Users = append(page.Users, User{
+			ID:        row.ID,
+			Nickname:  row.Nickname,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Presence:  &p,
+		})
+	}
+
+	return page, nil
+}
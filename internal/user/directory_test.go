@@ -0,0 +1,34 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDirectoryCursorRoundTrip(t *testing.T) {
+	want := DirectoryCursor{Nickname: "a|b", ID: uuid.New()}
+
+	got, err := DecodeDirectoryCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodeDirectoryCursor: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNicknamePrefixPattern(t *testing.T) {
+	tests := map[string]string{
+		"Ann":   "ann%",
+		"50%":   `50\%%`,
+		"a_b":   `a\_b%`,
+		`back\`: `back\\%`,
+	}
+
+	for prefix, want := range tests {
+		if got := nicknamePrefixPattern(prefix); got != want {
+			t.Errorf("nicknamePrefixPattern(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
@@ -2,34 +2,78 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/fernandofreamunde/ika/internal/auth"
 	"github.com/fernandofreamunde/ika/internal/db"
+	"github.com/fernandofreamunde/ika/internal/presence"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrUserNotFound  = errors.New("User not found.")
+	ErrEmailTaken    = errors.New("User with this email already exists.")
+	ErrMissingFields = errors.New("Email, password and nickname are required.")
+)
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
 	Nickname  string    `json:"nickname"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Presence is only filled in when looking at other users.
+	Presence *presence.Presence `json:"presence,omitempty"`
+}
+
+// Account is the user's own record, the only place their email and privacy
+// settings are shown.
+type Account struct {
+	User
+	Email string `json:"email"`
+	// ContactsOnly users only accept direct chatrooms from their contacts.
+	ContactsOnly bool `json:"contacts_only"`
+	// Discoverable users can be found in the user directory.
+	Discoverable bool `json:"discoverable"`
+}
+
+func newAccount(u db.User) Account {
+	return Account{
+		User: User{
+			ID:        u.ID,
+			Nickname:  u.Nickname,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+		},
+		Email:        u.Email,
+		ContactsOnly: u.ContactsOnly,
+		Discoverable: u.Discoverable,
+	}
 }
 
 type UserParams struct {
 	Email    string `json:"email"`
 	Nickname string `json:"nickname"`
 	Password string `json:"password"`
+	// ContactsOnly and Discoverable are left as they are when not set.
+	ContactsOnly *bool `json:"contacts_only"`
+	Discoverable *bool `json:"discoverable"`
 }
 
-func UpdateUser(dbUser db.User, data UserParams, ctx context.Context, dbq func() *db.Queries) (User, error) {
+func UpdateUser(dbUser db.User, data UserParams, ctx context.Context, dbq func() *db.Queries) (Account, error) {
 
 	if data.Email == "" {
 		data.Email = dbUser.Email
 	}
 
+	if data.Email != dbUser.Email {
+		if _, err := dbq().FindUserByEmail(ctx, data.Email); err == nil {
+			return Account{}, ErrEmailTaken
+		}
+	}
+
 	if data.Nickname == "" {
 		data.Nickname = dbUser.Nickname
 	}
@@ -42,41 +86,47 @@ func UpdateUser(dbUser db.User, data UserParams, ctx context.Context, dbq func()
 	}
 
 	if err != nil {
-		return User{}, err
+		return Account{}, err
+	}
+
+	contactsOnly := dbUser.ContactsOnly
+	if data.ContactsOnly != nil {
+		contactsOnly = *data.ContactsOnly
+	}
+
+	discoverable := dbUser.Discoverable
+	if data.Discoverable != nil {
+		discoverable = *data.Discoverable
 	}
 
 	d := db.UpdateUserParams{
 		Email:          data.Email,
 		Nickname:       data.Nickname,
 		HashedPassword: data.Password,
+		ContactsOnly:   contactsOnly,
+		Discoverable:   discoverable,
 		ID:             dbUser.ID,
 	}
 
 	updatedUser, err := dbq().UpdateUser(ctx, d)
 	if err != nil {
-		return User{}, err
+		return Account{}, err
 	}
 
-	return User{
-		ID:        updatedUser.ID,
-		Email:     updatedUser.Email,
-		Nickname:  updatedUser.Nickname,
-		CreatedAt: updatedUser.CreatedAt,
-		UpdatedAt: updatedUser.UpdatedAt,
-	}, nil
+	return newAccount(updatedUser), nil
 }
 
-func CreateUser(params UserParams, ctx context.Context, dbq func() *db.Queries) (User, error) {
+func CreateUser(params UserParams, ctx context.Context, dbq func() *db.Queries) (Account, error) {
 	id, _ := uuid.NewUUID()
 
 	if params.Email == "" || params.Password == "" || params.Nickname == "" {
-		return User{}, fmt.Errorf("email, password and nickname are mandatory fields!")
+		return Account{}, ErrMissingFields
 	}
 
 	var err error
 	params.Password, err = auth.HashPassword(params.Password)
 	if err != nil {
-		return User{}, err
+		return Account{}, err
 	}
 
 	data := db.CreateUserParams{
@@ -88,40 +138,117 @@ func CreateUser(params UserParams, ctx context.Context, dbq func() *db.Queries)
 
 	_, err = dbq().FindUserByEmail(ctx, params.Email)
 	if err == nil {
-		return User{}, fmt.Errorf("User with this email already exists!")
+		return Account{}, ErrEmailTaken
 	}
 	dbuser, err := dbq().CreateUser(ctx, data)
 
+	if err != nil {
+		return Account{}, err
+	}
+
+	return newAccount(dbuser), nil
+}
+
+// GetAccount returns the user's own record.
+func GetAccount(id uuid.UUID, ctx context.Context, dbq func() *db.Queries) (Account, error) {
+
+	dbuser, err := dbq().FindUserById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Account{}, ErrUserNotFound
+	}
+	if err != nil {
+		return Account{}, fmt.Errorf("Err finding user: %v", err)
+	}
+
+	return newAccount(dbuser), nil
+}
+
+// GetUserById returns the user together with their presence as known by the tracker.
+func GetUserById(id string, tracker *presence.Tracker, ctx context.Context, dbq func() *db.Queries) (User, error)  {
+
+	dbuser, err := findUser(id, ctx, dbq)
 	if err != nil {
 		return User{}, err
 	}
 
-	return User{
-		ID:        dbuser.ID,
-		Email:     dbuser.Email,
-		Nickname:  dbuser.Nickname,
-		CreatedAt: dbuser.CreatedAt,
-		UpdatedAt: dbuser.UpdatedAt,
-	}, nil
+	return newUser(dbuser, tracker), nil
 }
 
-func GetUserById(id string, ctx context.Context, dbq func() *db.Queries) (User, error)  {
-	
+func findUser(id string, ctx context.Context, dbq func() *db.Queries) (db.User, error) {
+
 	userUuid, err := uuid.Parse(id)
 	if err != nil {
-		return User{}, fmt.Errorf("Invalid Friend ID.")
+		return db.User{}, ErrUserNotFound
 	}
 
 	dbuser, err := dbq().FindUserById(ctx, userUuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.User{}, ErrUserNotFound
+	}
 	if err != nil {
-		return User{}, fmt.Errorf("Invalid Friend ID.")
+		return db.User{}, fmt.Errorf("Err finding user: %v", err)
 	}
 
+	return dbuser, nil
+}
+
+func newUser(u db.User, tracker *presence.Tracker) User {
+	p := tracker.Lookup(u.ID)
+
 	return User{
-		ID:        dbuser.ID,
-		Email:     dbuser.Email,
-		Nickname:  dbuser.Nickname,
-		CreatedAt: dbuser.CreatedAt,
-		UpdatedAt: dbuser.UpdatedAt,
-	}, nil
+		ID:        u.ID,
+		Nickname:  u.Nickname,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Presence:  &p,
+	}
+}
+
+// ViewUser returns the user as the viewer gets to see them. Users who blocked
+// one another do not exist to each other, and users who are not discoverable
+// are only found by their contacts and those they share a chatroom with.
+func ViewUser(viewerId uuid.UUID, id string, tracker *presence.Tracker, ctx context.Context, dbq func() *db.Queries) (User, error) {
+
+	dbuser, err := findUser(id, ctx, dbq)
+	if err != nil {
+		return User{}, err
+	}
+
+	u := newUser(dbuser, tracker)
+	if u.ID == viewerId {
+		return u, nil
+	}
+
+	blocked, err := IsBlockedBetween(viewerId, u.ID, ctx, dbq)
+	if err != nil {
+		return User{}, err
+	}
+	if blocked {
+		return User{}, ErrUserNotFound
+	}
+
+	if dbuser.Discoverable {
+		return u, nil
+	}
+
+	contacts, err := AreContacts(viewerId, u.ID, ctx, dbq)
+	if err != nil {
+		return User{}, err
+	}
+	if contacts {
+		return u, nil
+	}
+
+	shared, err := dbq().ShareChatroom(ctx, db.ShareChatroomParams{
+		ParticipantID:   uuid.NullUUID{UUID: viewerId, Valid: true},
+		ParticipantID_2: uuid.NullUUID{UUID: u.ID, Valid: true},
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("Err checking shared chatrooms: %v", err)
+	}
+	if !shared {
+		return User{}, ErrUserNotFound
+	}
+
+	return u, nil
 }
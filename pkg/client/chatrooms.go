@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func chatroomPath(roomID uuid.UUID) string {
+	return "/api/chatrooms/" + roomID.String()
+}
+
+// Chatrooms lists the user's chatrooms with their unread counts.
+func (c *Client) Chatrooms(ctx context.Context) ([]ChatroomSummary, error) {
+	var rooms []ChatroomSummary
+	err := c.do(ctx, "GET", "/api/chatrooms", nil, &rooms)
+	return rooms, err
+}
+
+// CreateDirectChatroom opens the direct chatroom with friendID, or returns it
+// when it already exists.
+func (c *Client) CreateDirectChatroom(ctx context.Context, friendID uuid.UUID) (Chatroom, error) {
+	params := struct {
+		Type     string    `json:"type"`
+		FriendID uuid.UUID `json:"friend_id"`
+	}{"direct", friendID}
+
+	var room Chatroom
+	err := c.do(ctx, "POST", "/api/chatrooms", params, &room)
+	return room, err
+}
+
+// CreateGroupChatroom creates a group chatroom owned by the user.
+func (c *Client) CreateGroupChatroom(ctx context.Context, name string, participantIDs []uuid.UUID) (Chatroom, error) {
+	params := struct {
+		Type           string      `json:"type"`
+		Name           string      `json:"name"`
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}{"group", name, participantIDs}
+
+	var room Chatroom
+	err := c.do(ctx, "POST", "/api/chatrooms", params, &room)
+	return room, err
+}
+
+func (c *Client) RenameChatroom(ctx context.Context, roomID uuid.UUID, name string) (Chatroom, error) {
+	params := struct {
+		Name string `json:"name"`
+	}{name}
+
+	var room Chatroom
+	err := c.do(ctx, "PATCH", chatroomPath(roomID), params, &room)
+	return room, err
+}
+
+// DeleteChatroom deletes a group chatroom for all of its participants.
+func (c *Client) DeleteChatroom(ctx context.Context, roomID uuid.UUID) error {
+	return c.do(ctx, "DELETE", chatroomPath(roomID)+"?for=everyone", nil, nil)
+}
+
+func (c *Client) LeaveChatroom(ctx context.Context, roomID uuid.UUID) error {
+	return c.do(ctx, "DELETE", chatroomPath(roomID), nil, nil)
+}
+
+// MarkRead marks the messages of the chatroom up to messageID as read.
+func (c *Client) MarkRead(ctx context.Context, roomID, messageID uuid.UUID) error {
+	params := struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}{messageID}
+
+	return c.do(ctx, "POST", chatroomPath(roomID)+"/read", params, nil)
+}
+
+func (c *Client) Participants(ctx context.Context, roomID uuid.UUID) ([]Participant, error) {
+	var participants []Participant
+	err := c.do(ctx, "GET", chatroomPath(roomID)+"/participants", nil, &participants)
+	return participants, err
+}
+
+func (c *Client) AddParticipant(ctx context.Context, roomID, userID uuid.UUID) (User, error) {
+	params := struct {
+		ParticipantID uuid.UUID `json:"participant_id"`
+	}{userID}
+
+	var u User
+	err := c.do(ctx, "POST", chatroomPath(roomID)+"/participants", params, &u)
+	return u, err
+}
+
+func (c *Client) RemoveParticipant(ctx context.Context, roomID, userID uuid.UUID) error {
+	return c.do(ctx, "DELETE", chatroomPath(roomID)+"/participants/"+userID.String(), nil, nil)
+}
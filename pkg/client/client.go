@@ -0,0 +1,202 @@
+// Package client is a Go client for the ika API, for bots, integrations and
+// tests that would otherwise hand-roll HTTP calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Client calls the API as a single user. Requests failing with 401 are
+// retried once after refreshing the access token with the refresh token.
+// It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+
+	// refreshMu makes concurrent requests share a single refresh.
+	refreshMu sync.Mutex
+}
+
+// New returns a client for the API served at baseURL, e.g.
+// http://localhost:8080. A nil httpClient uses http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// SetTokens sets the tokens requests are made with, e.g. ones saved from an
+// earlier login.
+func (c *Client) SetTokens(token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.refreshToken = token, refreshToken
+}
+
+// Tokens returns the current access and refresh tokens.
+func (c *Client) Tokens() (token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, c.refreshToken
+}
+
+// Register creates a user. It does not log in.
+func (c *Client) Register(ctx context.Context, params RegisterParams) (Account, error) {
+	var a Account
+	err := c.call(ctx, "POST", "/api/users", "", params, &a)
+	return a, err
+}
+
+// Login logs the user in; later requests are made as them.
+func (c *Client) Login(ctx context.Context, email, password string) (LoginResponse, error) {
+	params := struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{email, password}
+
+	var resp LoginResponse
+	if err := c.call(ctx, "POST", "/api/login", "", params, &resp); err != nil {
+		return LoginResponse{}, err
+	}
+
+	c.SetTokens(resp.Token, resp.RefreshToken)
+	return resp, nil
+}
+
+// Refresh replaces the access token with a new one and returns it.
+func (c *Client) Refresh(ctx context.Context) (string, error) {
+	_, refreshToken := c.Tokens()
+	if refreshToken == "" {
+		return "", ErrNoRefreshToken
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.call(ctx, "POST", "/api/refresh", "ApiKey "+refreshToken, nil, &resp); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.token = resp.Token
+	c.mu.Unlock()
+	return resp.Token, nil
+}
+
+// Me returns the logged in user's own record.
+func (c *Client) Me(ctx context.Context) (Account, error) {
+	var a Account
+	err := c.do(ctx, "GET", "/api/users/me", nil, &a)
+	return a, err
+}
+
+// Revoke revokes the refresh token. The access token stays valid until it
+// expires.
+func (c *Client) Revoke(ctx context.Context) error {
+	_, refreshToken := c.Tokens()
+	if refreshToken == "" {
+		return ErrNoRefreshToken
+	}
+
+	if err := c.call(ctx, "POST", "/api/revoke", "ApiKey "+refreshToken, nil, nil); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.refreshToken = ""
+	c.mu.Unlock()
+	return nil
+}
+
+// do sends an authenticated request, refreshing the access token and trying
+// again once if it was rejected.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	token, refreshToken := c.Tokens()
+
+	err := c.call(ctx, method, path, "Bearer "+token, in, out)
+	var apiErr *Error
+	if refreshToken == "" || !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		return err
+	}
+
+	if err := c.refreshAfter(ctx, token); err != nil {
+		return err
+	}
+
+	token, _ = c.Tokens()
+	return c.call(ctx, method, path, "Bearer "+token, in, out)
+}
+
+// refreshAfter refreshes the access token unless another request already
+// replaced the rejected one.
+func (c *Client) refreshAfter(ctx context.Context, rejected string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if token, _ := c.Tokens(); token != rejected {
+		return nil
+	}
+
+	_, err := c.Refresh(ctx)
+	return err
+}
+
+// call sends a single request with in as its JSON body and decodes the JSON
+// response into out. Either may be nil.
+func (c *Client) call(ctx context.Context, method, path, authorization string, in, out any) error {
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("Err encoding request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("Err creating request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Err sending request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return decodeError(res)
+	}
+
+	if out == nil || res.StatusCode == 204 {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("Err decoding response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrNoRefreshToken = errors.New("No refresh token, log in first.")
+
+// Error is an error response of the API. Match on Code, which never changes
+// once released; Message may.
+type Error struct {
+	StatusCode int          `json:"-"`
+	Code       string       `json:"code"`
+	Message    string       `json:"message"`
+	Fields     []FieldError `json:"fields,omitempty"`
+	RequestID  string       `json:"request_id,omitempty"`
+}
+
+// FieldError points at the part of the request that was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// decodeError reads the error response. Responses that do not come from the
+// API, e.g. from a proxy, keep their status text as the message.
+func decodeError(res *http.Response) error {
+	apiErr := &Error{}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr = &Error{Message: http.StatusText(res.StatusCode)}
+	}
+	apiErr.StatusCode = res.StatusCode
+
+	return apiErr
+}
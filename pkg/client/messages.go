@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+func messagePath(roomID, messageID uuid.UUID) string {
+	return chatroomPath(roomID) + "/messages/" + messageID.String()
+}
+
+// ListMessagesParams picks the page of messages to list. Without Before or
+// After the newest messages are returned.
+type ListMessagesParams struct {
+	// Before and After are the NextCursor of an earlier page; only one of
+	// them can be set.
+	Before string
+	After  string
+	// Limit is left to the server when 0.
+	Limit int
+}
+
+func (c *Client) ListMessages(ctx context.Context, roomID uuid.UUID, params ListMessagesParams) (MessagePage, error) {
+	query := url.Values{}
+	if params.Before != "" {
+		query.Set("before", params.Before)
+	}
+	if params.After != "" {
+		query.Set("after", params.After)
+	}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	path := chatroomPath(roomID) + "/messages"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var page MessagePage
+	err := c.do(ctx, "GET", path, nil, &page)
+	return page, err
+}
+
+type SendMessageParams struct {
+	Content string
+	// ReplyToID optionally points at a message of the same chatroom.
+	ReplyToID *uuid.UUID
+}
+
+func (c *Client) SendMessage(ctx context.Context, roomID uuid.UUID, params SendMessageParams) (Message, error) {
+	body := struct {
+		Content   string     `json:"content"`
+		ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	}{params.Content, params.ReplyToID}
+
+	var msg Message
+	err := c.do(ctx, "POST", chatroomPath(roomID)+"/messages", body, &msg)
+	return msg, err
+}
+
+func (c *Client) EditMessage(ctx context.Context, roomID, messageID uuid.UUID, content string) (Message, error) {
+	body := struct {
+		Content string `json:"content"`
+	}{content}
+
+	var msg Message
+	err := c.do(ctx, "PATCH", messagePath(roomID, messageID), body, &msg)
+	return msg, err
+}
+
+func (c *Client) DeleteMessage(ctx context.Context, roomID, messageID uuid.UUID) error {
+	return c.do(ctx, "DELETE", messagePath(roomID, messageID), nil, nil)
+}
+
+// Thread returns the message with its replies, oldest first.
+func (c *Client) Thread(ctx context.Context, roomID, messageID uuid.UUID) (Thread, error) {
+	var thread Thread
+	err := c.do(ctx, "GET", messagePath(roomID, messageID)+"/thread", nil, &thread)
+	return thread, err
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	EventMessageCreated  = "message.created"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventMessageRead     = "message.read"
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+	EventChatroomUpdated = "chatroom.updated"
+	EventChatroomDeleted = "chatroom.deleted"
+	EventTypingStarted   = "typing.started"
+	EventTypingStopped   = "typing.stopped"
+	EventChatroomResync  = "chatroom.resync"
+)
+
+// Event is a notification about one of the user's chatrooms. ID is the ID of
+// the resource the event is about, e.g. the message. Seq numbers the events
+// of the chatroom, it is 0 for events like typing that are never replayed.
+type Event struct {
+	Type       string          `json:"type"`
+	Seq        int64           `json:"seq,omitempty"`
+	ID         uuid.UUID       `json:"id"`
+	ChatroomID uuid.UUID       `json:"chatroom_id"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Decode decodes the data of the event into v, e.g. a Message for message
+// events or a Chatroom for chatroom.updated.
+func (e Event) Decode(v any) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("Err decoding %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Subscription receives the events of every chatroom the user participates
+// in, including the ones they join later, over a websocket.
+type Subscription struct {
+	conn   *websocket.Conn
+	events chan Event
+	done   chan struct{}
+
+	closeOnce sync.Once
+	writeMu   sync.Mutex
+	// err is set before events is closed.
+	err error
+}
+
+// Subscribe opens the websocket. The subscription ends when ctx is done or
+// Close is called.
+func (c *Client) Subscribe(ctx context.Context) (*Subscription, error) {
+	token, refreshToken := c.Tokens()
+
+	conn, err := c.dial(ctx, token)
+	var apiErr *Error
+	if refreshToken != "" && errors.As(err, &apiErr) && apiErr.StatusCode == 401 {
+		if err := c.refreshAfter(ctx, token); err != nil {
+			return nil, err
+		}
+		token, _ = c.Tokens()
+		conn, err = c.dial(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		conn:   conn,
+		events: make(chan Event, 16),
+		done:   make(chan struct{}),
+	}
+	go s.read()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+
+	return s, nil
+}
+
+func (c *Client) dial(ctx context.Context, token string) (*websocket.Conn, error) {
+	url := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/api/ws"
+	header := http.Header{"Authorization": {"Bearer " + token}}
+
+	conn, res, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if errors.Is(err, websocket.ErrBadHandshake) && res != nil {
+		return nil, decodeError(res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Err opening websocket: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Events delivers the events as they come. It is closed when the
+// subscription ends, after which Err tells why.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the error that ended the subscription, nil if it was closed.
+// It must only be called once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// SetTyping tells the other participants of the chatroom whether the user is
+// typing in it.
+func (s *Subscription) SetTyping(roomID uuid.UUID, typing bool) error {
+	msg := struct {
+		Type       string    `json:"type"`
+		ChatroomID uuid.UUID `json:"chatroom_id"`
+	}{"typing.stopped", roomID}
+	if typing {
+		msg.Type = "typing"
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("Err sending typing: %w", err)
+	}
+	return nil
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.writeMu.Lock()
+		s.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+
+		err = s.conn.Close()
+	})
+	return err
+}
+
+func (s *Subscription) read() {
+	defer close(s.events)
+
+	for {
+		var e Event
+		if err := s.conn.ReadJSON(&e); err != nil {
+			select {
+			case <-s.done:
+			default:
+				s.err = fmt.Errorf("Err reading event: %w", err)
+				s.Close()
+			}
+			return
+		}
+
+		select {
+		case s.events <- e:
+		case <-s.done:
+			return
+		}
+	}
+}
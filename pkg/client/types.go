@@ -0,0 +1,131 @@
+package client
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The types below mirror the API's responses, documented at
+// /api/openapi.json.
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	Nickname  string    `json:"nickname"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Presence is only known for other users.
+	Presence *Presence `json:"presence,omitempty"`
+}
+
+// Account is the user's own record, the only place their email and privacy
+// settings are known.
+type Account struct {
+	User
+	Email        string `json:"email"`
+	ContactsOnly bool   `json:"contacts_only"`
+	Discoverable bool   `json:"discoverable"`
+}
+
+type Presence struct {
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+type RegisterParams struct {
+	Email    string `json:"email"`
+	Nickname string `json:"nickname"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	User         Account `json:"user"`
+	Token        string  `json:"token"`
+	RefreshToken string  `json:"refresh_token"`
+}
+
+type Chatroom struct {
+	ID   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	// Name is nil for direct chatrooms.
+	Name      *string   `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChatroomSummary is a chatroom as listed for one of its participants.
+type ChatroomSummary struct {
+	Chatroom
+	LastReadMessageID *uuid.UUID `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	UnreadCount       int64      `json:"unread_count"`
+}
+
+type Participant struct {
+	User
+	Role   string `json:"role"`
+	Typing bool   `json:"typing"`
+}
+
+type Message struct {
+	ID         uuid.UUID  `json:"id"`
+	ChatroomID uuid.UUID  `json:"chatroom_id"`
+	AuthorID   uuid.UUID  `json:"author_id"`
+	Type       string     `json:"type"`
+	Content    string     `json:"content"`
+	ReplyToID  *uuid.UUID `json:"reply_to_id"`
+	SentAt     time.Time  `json:"sent_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Edited     bool       `json:"edited"`
+	Deleted    bool       `json:"deleted"`
+	// ReadBy is only filled in for direct chatrooms.
+	ReadBy     []uuid.UUID     `json:"read_by,omitempty"`
+	ReplyCount int64           `json:"reply_count"`
+	ReplyTo    *MessagePreview `json:"reply_to,omitempty"`
+	Reactions  []ReactionCount `json:"reactions"`
+	Attachment *Attachment     `json:"attachment,omitempty"`
+}
+
+type MessagePreview struct {
+	ID       uuid.UUID `json:"id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	Content  string    `json:"content"`
+	Deleted  bool      `json:"deleted"`
+}
+
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+type Attachment struct {
+	ID        uuid.UUID  `json:"id"`
+	MessageID uuid.UUID  `json:"message_id"`
+	URL       string     `json:"url"`
+	Name      string     `json:"name"`
+	MimeType  string     `json:"mime_type"`
+	Size      int64      `json:"size"`
+	Checksum  string     `json:"checksum"`
+	Width     int32      `json:"width,omitempty"`
+	Height    int32      `json:"height,omitempty"`
+	Thumbnail *Thumbnail `json:"thumbnail,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Thread struct {
+	Message Message   `json:"message"`
+	Replies []Message `json:"replies"`
+}
@@ -0,0 +1,44 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, message_id, name, mime_type, size, checksum, storage_key, width, height, thumbnail_status, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+RETURNING *;
+
+-- name: FindAttachmentById :one
+SELECT * FROM attachments WHERE id = $1;
+
+-- name: FindAttachmentsByRoomBetween :many
+SELECT a.*
+FROM attachments AS a
+INNER JOIN messages AS m ON m.id = a.message_id
+WHERE m.chatroom_id = $1 AND m.sent_at BETWEEN $2 AND $3;
+
+-- name: FindAttachmentsByThread :many
+SELECT a.*
+FROM attachments AS a
+INNER JOIN messages AS m ON m.id = a.message_id
+WHERE m.id = $1 OR m.reply_to_id = $1;
+
+-- name: FindPendingThumbnailIds :many
+SELECT id FROM attachments
+WHERE thumbnail_status = 'pending'
+ORDER BY created_at ASC;
+
+-- name: SetAttachmentThumbnail :exec
+UPDATE attachments
+SET thumbnail_status = 'ready', thumbnail_key = $2, thumbnail_width = $3, thumbnail_height = $4
+WHERE id = $1;
+
+-- name: SetAttachmentThumbnailStatus :exec
+UPDATE attachments
+SET thumbnail_status = $2
+WHERE id = $1;
+
+-- name: DeleteAttachmentsByMessageId :many
+DELETE FROM attachments WHERE message_id = $1
+RETURNING storage_key, thumbnail_key;
+
+-- name: DeleteAttachmentsByChatroomId :many
+DELETE FROM attachments AS a
+USING messages AS m
+WHERE m.id = a.message_id AND m.chatroom_id = $1
+RETURNING a.storage_key, a.thumbnail_key;
@@ -0,0 +1,24 @@
+-- name: CreateChatroomEvent :one
+-- The counter row stays locked until the transaction ends, so the events of
+-- a chatroom are committed in the order they are numbered.
+WITH next AS (
+	INSERT INTO chatroom_event_seqs (chatroom_id, seq)
+	VALUES ($1, 1)
+	ON CONFLICT (chatroom_id) DO UPDATE SET seq = chatroom_event_seqs.seq + 1
+	RETURNING seq
+)
+INSERT INTO chatroom_events (chatroom_id, seq, type, resource_id, data, created_at)
+SELECT $1, next.seq, $2, $3, $4, NOW() FROM next
+RETURNING *;
+
+-- name: FindChatroomEventSeq :one
+SELECT seq FROM chatroom_event_seqs WHERE chatroom_id = $1;
+
+-- name: FindChatroomEventsAfter :many
+SELECT * FROM chatroom_events
+WHERE chatroom_id = $1 AND seq > $2
+ORDER BY seq ASC
+LIMIT $3;
+
+-- name: DeleteChatroomEventsBefore :execrows
+DELETE FROM chatroom_events WHERE created_at < $1;
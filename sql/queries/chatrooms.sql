@@ -24,9 +24,77 @@ WHERE id = $3
 RETURNING *;
 
 -- name: ChatroomAddParticipant :exec
-INSERT INTO chatrooms_participants(chatroom_id, participant_id)
-VALUES ($1, $2);
+INSERT INTO chatrooms_participants(chatroom_id, participant_id, role)
+VALUES ($1, $2, $3);
 
 -- name: ChatroomRemoveParticipant :exec
 DELETE FROM chatrooms_participants
 WHERE chatroom_id = $1 AND participant_id = $2;
+
+-- name: FindParticipantsByChatRoomId :many
+SELECT u.*, cp.role FROM users AS u
+INNER JOIN chatrooms_participants AS cp ON u.id = cp.participant_id
+WHERE cp.chatroom_id = $1;
+
+-- name: FindParticipantRole :one
+SELECT role FROM chatrooms_participants
+WHERE chatroom_id = $1 AND participant_id = $2;
+
+-- name: FindOwnerSuccessor :one
+-- Admins come first, then whoever joined the chatroom first.
+SELECT participant_id FROM chatrooms_participants
+WHERE chatroom_id = $1 AND role <> 'owner'
+ORDER BY CASE role WHEN 'admin' THEN 0 ELSE 1 END, joined_at ASC, participant_id ASC
+LIMIT 1;
+
+-- name: UpdateParticipantRole :exec
+UPDATE chatrooms_participants
+SET role = $1
+WHERE chatroom_id = $2 AND participant_id = $3;
+
+-- name: FindUsersChatroomsWithUnreadCount :many
+SELECT cr.*, cp.last_read_message_id, cp.last_read_at,
+	(
+		SELECT COUNT(*) FROM messages AS m
+		LEFT JOIN messages AS lr ON lr.id = cp.last_read_message_id
+		WHERE m.chatroom_id = cr.id
+		AND m.author_id IS DISTINCT FROM cp.participant_id
+		AND m.type <> 'deleted'
+		AND (lr.id IS NULL OR (m.sent_at, m.id) > (lr.sent_at, lr.id))
+	) AS unread_count
+FROM chatrooms AS cr
+INNER JOIN chatrooms_participants AS cp ON cr.id = cp.chatroom_id
+WHERE cp.participant_id = $1;
+
+-- name: UpdateParticipantLastRead :exec
+UPDATE chatrooms_participants AS cp
+SET last_read_message_id = $1, last_read_at = NOW()
+WHERE cp.chatroom_id = $2 AND cp.participant_id = $3
+AND NOT EXISTS (
+	SELECT 1 FROM messages AS cur, messages AS new
+	WHERE cur.id = cp.last_read_message_id AND new.id = $1
+	AND (cur.sent_at, cur.id) >= (new.sent_at, new.id)
+);
+
+-- name: FindParticipantsReadPositions :many
+SELECT cp.participant_id, m.sent_at, m.id
+FROM chatrooms_participants AS cp
+INNER JOIN messages AS m ON m.id = cp.last_read_message_id
+WHERE cp.chatroom_id = $1;
+
+-- name: FindDirectChatroom :one
+SELECT cr.* FROM chatrooms AS cr
+INNER JOIN direct_chatrooms AS dc ON dc.chatroom_id = cr.id
+WHERE dc.user_low_id = $1 AND dc.user_high_id = $2;
+
+-- name: ClaimDirectChatroom :execrows
+INSERT INTO direct_chatrooms (chatroom_id, user_low_id, user_high_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: ShareChatroom :one
+SELECT EXISTS(
+	SELECT 1 FROM chatrooms_participants AS a
+	INNER JOIN chatrooms_participants AS b ON b.chatroom_id = a.chatroom_id
+	WHERE a.participant_id = $1 AND b.participant_id = $2
+);
@@ -0,0 +1,39 @@
+-- name: CreateFriendRequest :one
+INSERT INTO contacts (requester_id, addressee_id, status, created_at, updated_at)
+VALUES ($1, $2, 'pending', NOW(), NOW())
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: FindContactBetween :one
+SELECT * FROM contacts
+WHERE (requester_id = $1 AND addressee_id = $2)
+OR (requester_id = $2 AND addressee_id = $1);
+
+-- name: AcceptFriendRequest :one
+UPDATE contacts
+SET status = 'accepted', updated_at = NOW()
+WHERE requester_id = $1 AND addressee_id = $2 AND status = 'pending'
+RETURNING *;
+
+-- name: DeleteFriendRequest :execrows
+DELETE FROM contacts
+WHERE requester_id = $1 AND addressee_id = $2 AND status = 'pending';
+
+-- name: DeleteContactBetween :execrows
+DELETE FROM contacts
+WHERE ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+AND status = 'accepted';
+
+-- name: FindContactsByUserId :many
+SELECT u.*, c.requester_id, c.status, c.updated_at AS since
+FROM contacts AS c
+INNER JOIN users AS u ON u.id = CASE WHEN c.requester_id = $1 THEN c.addressee_id ELSE c.requester_id END
+WHERE c.requester_id = $1 OR c.addressee_id = $1
+ORDER BY c.updated_at DESC;
+
+-- name: AreContacts :one
+SELECT EXISTS(
+	SELECT 1 FROM contacts
+	WHERE ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+	AND status = 'accepted'
+);
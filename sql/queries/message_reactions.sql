@@ -0,0 +1,24 @@
+-- name: AddMessageReaction :exec
+INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveMessageReaction :exec
+DELETE FROM message_reactions
+WHERE message_id = $1 AND user_id = $2 AND emoji = $3;
+
+-- name: FindReactionCountsByRoomBetween :many
+SELECT mr.message_id, mr.emoji, COUNT(*) AS count, BOOL_OR(mr.user_id = $4) AS reacted
+FROM message_reactions AS mr
+INNER JOIN messages AS m ON m.id = mr.message_id
+WHERE m.chatroom_id = $1 AND m.sent_at BETWEEN $2 AND $3
+GROUP BY mr.message_id, mr.emoji
+ORDER BY MIN(mr.created_at) ASC;
+
+-- name: FindReactionCountsByThread :many
+SELECT mr.message_id, mr.emoji, COUNT(*) AS count, BOOL_OR(mr.user_id = $2) AS reacted
+FROM message_reactions AS mr
+INNER JOIN messages AS m ON m.id = mr.message_id
+WHERE m.id = $1 OR m.reply_to_id = $1
+GROUP BY mr.message_id, mr.emoji
+ORDER BY MIN(mr.created_at) ASC;
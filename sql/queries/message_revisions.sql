@@ -0,0 +1,10 @@
+-- name: CreateMessageRevision :one
+INSERT INTO message_revisions (id, message_id, editor_id, content, created_at)
+VALUES ($1, $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: FindRevisionsByMessageId :many
+SELECT *
+FROM message_revisions
+WHERE message_id = $1
+ORDER BY created_at ASC;
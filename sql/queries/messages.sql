@@ -1,20 +1,60 @@
 -- name: CreateMessage :one
-INSERT INTO messages (id, type, content, author_id, chatroom_id, sent_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+INSERT INTO messages (id, type, content, author_id, chatroom_id, reply_to_id, sent_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
 RETURNING *;
 
 -- name: DeleteMessage :exec
 DELETE FROM messages WHERE id = $1;
 
+-- name: FindMessageById :one
+SELECT * FROM messages WHERE id = $1;
+
 -- name: FindMessagesByRoomById :many
-SELECT * 
+SELECT m.*,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $2;
+
+-- name: FindMessagesByRoomBefore :many
+SELECT m.*,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+AND (m.sent_at < $2 OR (m.sent_at = $2 AND m.id < $3))
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $4;
+
+-- name: FindMessagesByRoomAfter :many
+SELECT m.*,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.reply_to_id = m.id AND r.type <> 'deleted') AS reply_count,
+	p.author_id AS reply_to_author_id, p.type AS reply_to_type, p.content AS reply_to_content
+FROM messages AS m
+LEFT JOIN messages AS p ON p.id = m.reply_to_id
+WHERE m.chatroom_id = $1
+AND (m.sent_at > $2 OR (m.sent_at = $2 AND m.id > $3))
+ORDER BY m.sent_at ASC, m.id ASC
+LIMIT $4;
+
+-- name: FindRepliesByMessageId :many
+SELECT *
 FROM messages
-WHERE chatroom_id = $1
-ORDER BY sent_at DESC;
+WHERE reply_to_id = $1
+ORDER BY sent_at ASC, id ASC;
+
+-- name: TombstoneMessage :one
+UPDATE messages
+SET type = 'deleted', content = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
 
 -- name: UpdateMessage :one
 UPDATE messages
 SET content = $1, updated_at = NOW()
 WHERE id = $2
 RETURNING *;
-
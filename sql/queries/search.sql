@@ -0,0 +1,24 @@
+-- name: SearchMessages :many
+SELECT m.id, m.chatroom_id, m.author_id, m.sent_at,
+	ts_headline('english', translate(COALESCE(m.content, ''), chr(2) || chr(3), ''), q.query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+FROM messages AS m
+INNER JOIN chatrooms_participants AS cp ON cp.chatroom_id = m.chatroom_id
+CROSS JOIN websearch_to_tsquery('english', $2) AS q(query)
+WHERE cp.participant_id = $1
+AND m.type <> 'deleted'
+AND to_tsvector('english', COALESCE(m.content, '')) @@ q.query
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $3;
+
+-- name: SearchMessagesBefore :many
+SELECT m.id, m.chatroom_id, m.author_id, m.sent_at,
+	ts_headline('english', translate(COALESCE(m.content, ''), chr(2) || chr(3), ''), q.query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+FROM messages AS m
+INNER JOIN chatrooms_participants AS cp ON cp.chatroom_id = m.chatroom_id
+CROSS JOIN websearch_to_tsquery('english', $2) AS q(query)
+WHERE cp.participant_id = $1
+AND m.type <> 'deleted'
+AND to_tsvector('english', COALESCE(m.content, '')) @@ q.query
+AND (m.sent_at < $3 OR (m.sent_at = $3 AND m.id < $4))
+ORDER BY m.sent_at DESC, m.id DESC
+LIMIT $5;
@@ -0,0 +1,26 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: FindBlockedUsers :many
+SELECT u.*, ub.created_at AS blocked_at
+FROM user_blocks AS ub
+INNER JOIN users AS u ON u.id = ub.blocked_id
+WHERE ub.blocker_id = $1
+ORDER BY ub.created_at DESC;
+
+-- name: HasBlocked :one
+SELECT EXISTS(
+	SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2
+);
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS(
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = $1 AND blocked_id = $2)
+	OR (blocker_id = $2 AND blocked_id = $1)
+);
@@ -14,7 +14,33 @@ SELECT * FROM users WHERE id = $1;
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2, nickname = $3, updated_at = NOW()
-WHERE id = $4
+SET email = $1, hashed_password = $2, nickname = $3, contacts_only = $4, discoverable = $5, updated_at = NOW()
+WHERE id = $6
 RETURNING *;
 
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE id <> $1
+AND discoverable
+AND lower(nickname) LIKE $2
+AND NOT EXISTS(
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = $1 AND blocked_id = users.id)
+	OR (blocker_id = users.id AND blocked_id = $1)
+)
+ORDER BY lower(nickname) ASC, id ASC
+LIMIT $3;
+
+-- name: SearchUsersAfter :many
+SELECT * FROM users
+WHERE id <> $1
+AND discoverable
+AND lower(nickname) LIKE $2
+AND (lower(nickname) > $3 OR (lower(nickname) = $3 AND id > $4))
+AND NOT EXISTS(
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = $1 AND blocked_id = users.id)
+	OR (blocker_id = users.id AND blocked_id = $1)
+)
+ORDER BY lower(nickname) ASC, id ASC
+LIMIT $5;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_messages_chatroom_sent_at ON messages (chatroom_id, sent_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX idx_messages_chatroom_sent_at;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Group chatrooms are named by whoever creates or renames them, so several
+-- may share a name.
+ALTER TABLE chatrooms DROP CONSTRAINT chatrooms_name_key;
+
+-- +goose Down
+-- Names are unique again, so all but the oldest chatroom of each name get
+-- their id appended to it.
+UPDATE chatrooms AS cr
+SET name = cr.name || ' (' || cr.id || ')'
+FROM (
+	SELECT id, ROW_NUMBER() OVER (PARTITION BY name ORDER BY created_at, id) AS n
+	FROM chatrooms
+	WHERE name IS NOT NULL
+) AS named
+WHERE cr.id = named.id AND named.n > 1;
+
+ALTER TABLE chatrooms ADD CONSTRAINT chatrooms_name_key UNIQUE (name);
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chatrooms_participants ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'member';
+
+-- Existing group chatrooms get one of their participants as owner, so they
+-- can still be moderated.
+UPDATE chatrooms_participants SET role = 'owner'
+WHERE (chatroom_id, participant_id) IN (
+	SELECT DISTINCT ON (cp.chatroom_id) cp.chatroom_id, cp.participant_id
+	FROM chatrooms_participants AS cp
+	INNER JOIN chatrooms AS cr ON cr.id = cp.chatroom_id
+	WHERE cr.type = 'group'
+	ORDER BY cp.chatroom_id, cp.participant_id
+);
+
+-- +goose Down
+ALTER TABLE chatrooms_participants DROP COLUMN role;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE message_revisions(
+	id UUID PRIMARY KEY,
+	message_id UUID NOT NULL,
+	editor_id UUID,
+	content TEXT DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+	CONSTRAINT fk_editor_id FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_message_revisions_message_id ON message_revisions (message_id, created_at);
+
+-- +goose Down
+DROP TABLE message_revisions;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chatrooms_participants
+	ADD COLUMN last_read_message_id UUID DEFAULT NULL,
+	ADD COLUMN last_read_at TIMESTAMP DEFAULT NULL,
+	ADD CONSTRAINT fk_last_read_message_id FOREIGN KEY (last_read_message_id) REFERENCES messages(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE chatrooms_participants
+	DROP COLUMN last_read_at,
+	DROP COLUMN last_read_message_id;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE messages
+	ADD COLUMN reply_to_id UUID DEFAULT NULL,
+	ADD CONSTRAINT fk_reply_to_id FOREIGN KEY (reply_to_id) REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_messages_reply_to_id ON messages (reply_to_id);
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN reply_to_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE attachments(
+	id UUID PRIMARY KEY,
+	message_id UUID NOT NULL UNIQUE,
+	name VARCHAR(255) NOT NULL,
+	mime_type VARCHAR(255) NOT NULL,
+	size BIGINT NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	storage_key TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE attachments;
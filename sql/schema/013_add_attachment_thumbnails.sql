@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE attachments
+	ADD COLUMN width INTEGER,
+	ADD COLUMN height INTEGER,
+	ADD COLUMN thumbnail_status VARCHAR(16) NOT NULL DEFAULT 'none',
+	ADD COLUMN thumbnail_key TEXT,
+	ADD COLUMN thumbnail_width INTEGER,
+	ADD COLUMN thumbnail_height INTEGER;
+
+CREATE INDEX attachments_thumbnail_pending_idx ON attachments(created_at) WHERE thumbnail_status = 'pending';
+
+-- +goose Down
+DROP INDEX attachments_thumbnail_pending_idx;
+ALTER TABLE attachments
+	DROP COLUMN width,
+	DROP COLUMN height,
+	DROP COLUMN thumbnail_status,
+	DROP COLUMN thumbnail_key,
+	DROP COLUMN thumbnail_width,
+	DROP COLUMN thumbnail_height;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Full text search over message content, read as english. Deleted messages
+-- have no content and are indexed as an empty document.
+CREATE INDEX idx_messages_search ON messages USING GIN (to_tsvector('english', COALESCE(content, '')));
+
+-- +goose Down
+DROP INDEX idx_messages_search;
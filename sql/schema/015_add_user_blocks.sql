@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE user_blocks(
+	blocker_id UUID NOT NULL,
+	blocked_id UUID NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (blocker_id, blocked_id),
+	CONSTRAINT fk_blocker_id FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT fk_blocked_id FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT chk_not_self CHECK (blocker_id <> blocked_id)
+);
+
+-- Looking up who blocked a given user.
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+-- +goose Down
+DROP TABLE user_blocks;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE contacts(
+	requester_id UUID NOT NULL,
+	addressee_id UUID NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (requester_id, addressee_id),
+	CONSTRAINT fk_requester_id FOREIGN KEY (requester_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT fk_addressee_id FOREIGN KEY (addressee_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT chk_not_self CHECK (requester_id <> addressee_id)
+);
+
+-- A pair of users has at most one request or contact, whoever asked first.
+CREATE UNIQUE INDEX idx_contacts_pair ON contacts (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+CREATE INDEX idx_contacts_addressee_id ON contacts (addressee_id);
+
+ALTER TABLE users ADD COLUMN contacts_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN contacts_only;
+DROP TABLE contacts;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Users who do not want to be found by nickname opt out of the directory.
+ALTER TABLE users ADD COLUMN discoverable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Trigrams let the case insensitive nickname search match anywhere in the
+-- nickname, not only at its start.
+CREATE INDEX idx_users_nickname_trgm ON users USING GIN (lower(nickname) gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX idx_users_nickname_trgm;
+ALTER TABLE users DROP COLUMN discoverable;
@@ -0,0 +1,80 @@
+-- +goose Up
+-- Each pair of users has at most one direct chatroom. The pair is kept here
+-- rather than derived from the participants, since participants may leave.
+CREATE TABLE direct_chatrooms(
+	chatroom_id UUID PRIMARY KEY,
+	user_low_id UUID NOT NULL,
+	user_high_id UUID NOT NULL,
+	CONSTRAINT fk_chatroom_id FOREIGN KEY (chatroom_id) REFERENCES chatrooms(id) ON DELETE CASCADE,
+	CONSTRAINT fk_user_low_id FOREIGN KEY (user_low_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT fk_user_high_id FOREIGN KEY (user_high_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT chk_ordered_pair CHECK (user_low_id < user_high_id),
+	CONSTRAINT unique_direct_pair UNIQUE (user_low_id, user_high_id)
+);
+
+-- The users of existing direct chatrooms, from both the participants and the
+-- message authors as either of them may have left.
+CREATE TEMPORARY TABLE direct_members AS
+SELECT cp.chatroom_id, cp.participant_id AS user_id
+FROM chatrooms_participants AS cp
+INNER JOIN chatrooms AS cr ON cr.id = cp.chatroom_id
+WHERE cr.type = 'direct' AND cp.participant_id IS NOT NULL
+UNION
+SELECT m.chatroom_id, m.author_id
+FROM messages AS m
+INNER JOIN chatrooms AS cr ON cr.id = m.chatroom_id
+WHERE cr.type = 'direct' AND m.author_id IS NOT NULL;
+
+-- uuid has no min and max aggregates, its text form sorts the same way.
+CREATE TEMPORARY TABLE direct_pairs AS
+SELECT chatroom_id, MIN(user_id::text)::uuid AS user_low_id, MAX(user_id::text)::uuid AS user_high_id
+FROM direct_members
+GROUP BY chatroom_id
+HAVING COUNT(*) = 2;
+
+-- The oldest chatroom of each pair is kept, the others merged into it.
+CREATE TEMPORARY TABLE direct_merges AS
+SELECT dp.chatroom_id AS duplicate_id,
+	FIRST_VALUE(dp.chatroom_id) OVER (PARTITION BY dp.user_low_id, dp.user_high_id ORDER BY cr.created_at, cr.id) AS keep_id
+FROM direct_pairs AS dp
+INNER JOIN chatrooms AS cr ON cr.id = dp.chatroom_id;
+
+DELETE FROM direct_merges WHERE duplicate_id = keep_id;
+
+UPDATE messages AS m
+SET chatroom_id = dm.keep_id
+FROM direct_merges AS dm
+WHERE m.chatroom_id = dm.duplicate_id;
+
+INSERT INTO chatrooms_participants (chatroom_id, participant_id, role)
+SELECT DISTINCT dm.keep_id, cp.participant_id, 'member'
+FROM chatrooms_participants AS cp
+INNER JOIN direct_merges AS dm ON dm.duplicate_id = cp.chatroom_id
+ON CONFLICT DO NOTHING;
+
+-- Participants keep the furthest read position of the merged chatrooms.
+UPDATE chatrooms_participants AS cp
+SET last_read_message_id = latest.message_id, last_read_at = latest.last_read_at
+FROM (
+	SELECT DISTINCT ON (dm.keep_id, p.participant_id) dm.keep_id, p.participant_id, m.id AS message_id, p.last_read_at
+	FROM direct_merges AS dm
+	INNER JOIN chatrooms_participants AS p ON p.chatroom_id IN (dm.keep_id, dm.duplicate_id)
+	INNER JOIN messages AS m ON m.id = p.last_read_message_id
+	ORDER BY dm.keep_id, p.participant_id, m.sent_at DESC, m.id DESC
+) AS latest
+WHERE cp.chatroom_id = latest.keep_id AND cp.participant_id = latest.participant_id;
+
+DELETE FROM chatrooms WHERE id IN (SELECT duplicate_id FROM direct_merges);
+
+INSERT INTO direct_chatrooms (chatroom_id, user_low_id, user_high_id)
+SELECT chatroom_id, user_low_id, user_high_id
+FROM direct_pairs
+WHERE chatroom_id NOT IN (SELECT duplicate_id FROM direct_merges);
+
+DROP TABLE direct_merges;
+DROP TABLE direct_pairs;
+DROP TABLE direct_members;
+
+-- +goose Down
+-- Merged chatrooms are not split up again.
+DROP TABLE direct_chatrooms;
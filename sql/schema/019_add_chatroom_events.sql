@@ -0,0 +1,26 @@
+-- +goose Up
+-- Every chatroom numbers its events, so event streams can replay what a
+-- client missed from the last number it saw.
+CREATE TABLE chatroom_event_seqs(
+	chatroom_id UUID PRIMARY KEY,
+	seq BIGINT NOT NULL,
+	CONSTRAINT fk_chatroom_id FOREIGN KEY (chatroom_id) REFERENCES chatrooms(id) ON DELETE CASCADE
+);
+
+CREATE TABLE chatroom_events(
+	chatroom_id UUID NOT NULL,
+	seq BIGINT NOT NULL,
+	type VARCHAR(64) NOT NULL,
+	resource_id UUID NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (chatroom_id, seq),
+	CONSTRAINT fk_chatroom_id FOREIGN KEY (chatroom_id) REFERENCES chatrooms(id) ON DELETE CASCADE
+);
+
+-- Old events are pruned by age.
+CREATE INDEX chatroom_events_created_at_idx ON chatroom_events (created_at);
+
+-- +goose Down
+DROP TABLE chatroom_events;
+DROP TABLE chatroom_event_seqs;
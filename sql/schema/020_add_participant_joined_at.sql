@@ -0,0 +1,8 @@
+-- +goose Up
+-- An owner leaving a group chatroom hands it over to whoever joined first.
+-- When they joined was not known before, so existing participants all count
+-- as joined now.
+ALTER TABLE chatrooms_participants ADD COLUMN joined_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE chatrooms_participants DROP COLUMN joined_at;
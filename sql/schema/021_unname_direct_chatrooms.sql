@@ -0,0 +1,12 @@
+-- +goose Up
+-- Direct chatrooms have no name, clients show the other user instead.
+UPDATE chatrooms SET name = NULL WHERE type = 'direct';
+
+-- +goose Down
+-- Direct chatrooms were named after the nicknames of their pair of users.
+UPDATE chatrooms AS cr
+SET name = lo.nickname || ':' || hi.nickname
+FROM direct_chatrooms AS dc
+INNER JOIN users AS lo ON lo.id = dc.user_low_id
+INNER JOIN users AS hi ON hi.id = dc.user_high_id
+WHERE cr.id = dc.chatroom_id;